@@ -2,13 +2,15 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/radekwlsk/handauth/cmd/flags"
 	"github.com/radekwlsk/handauth/samples"
 	"github.com/radekwlsk/handauth/signature"
 	"io/ioutil"
+	"log"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,16 +33,80 @@ const (
 	MCYTResources
 )
 
-var UseFullResources = true
+// VerifierType selects how VerifyUser decides on samples.
+type VerifierType int
 
-func readSigCompUserSample(creator, user uint16, index uint8) (*samples.UserSample, error) {
+const (
+	// ScoreVerifier compares fused area scores with thresholds.
+	ScoreVerifier VerifierType = iota
+	// OneClassVerifier compares scores of one-class classifiers trained by
+	// EnrollUser with thresholds, 1 being the classifier boundary.
+	OneClassVerifier
+	// DichotomyVerifier compares Dichotomy scores of samples against
+	// reference samples kept by EnrollUser with thresholds.
+	DichotomyVerifier
+)
+
+// Config describes a verification pipeline: which resources samples are read
+// from, how they are preprocessed and how templates are built. Functions of
+// this package only read the configuration they are given, so pipelines with
+// different configurations can run in one process at the same time.
+type Config struct {
+	Resources ResourceType
+	// FullResources selects full SigComp dataset instead of the test one.
+	FullResources bool
+	// GPDSUsers is the number of GPDS users used.
+	GPDSUsers  int
+	Preprocess samples.PreprocessConfig
+	Model      signature.ModelConfig
+	// Prior of feature variances set in enrolled templates, none if nil.
+	Prior    *signature.VariancePrior
+	Verifier VerifierType
+	// OneClassNu is nu of one-class classifiers, see signature.TrainOneClass.
+	OneClassNu float64
+	// Dichotomy is the writer-independent classifier of DichotomyVerifier.
+	Dichotomy *signature.Dichotomy
+	// LeaveOneOut makes EnrollUser normalise template scores with
+	// signature.LeaveOneOutNorm.
+	LeaveOneOut bool
+	// ZNormCohort is the number of other users whose first genuine samples
+	// EnrollUser Z-normalises templates against, none if 0.
+	ZNormCohort int
+	// TNormCohort are templates of other users VerifyUser T-normalises
+	// scores against, none if empty.
+	TNormCohort []*signature.UserModel
+	// Calibration maps verification values to log-likelihood ratios, none
+	// if nil.
+	Calibration *signature.Calibration
+	// Styles is the max number of signature styles of a user, see
+	// signature.Model.Styles, a single style if below 2.
+	Styles int
+	// ReviewWidth is the width of the band above every threshold of
+	// VerifyUser; samples with values within it are sent to review.
+	ReviewWidth float64
+}
+
+// CheckPreprocess returns error if samples preprocessed with config are not
+// comparable with template, as it was enrolled with other deskewing.
+func CheckPreprocess(config Config, template *signature.UserModel) error {
+	if template.Model == nil {
+		return nil
+	}
+	if deskew := template.Model.Config().Deskew; deskew != config.Preprocess.Deskew {
+		return fmt.Errorf("user %d template enrolled with deskew %v, samples preprocessed with deskew %v",
+			template.Id, deskew, config.Preprocess.Deskew)
+	}
+	return nil
+}
+
+func readSigCompUserSample(full bool, creator, user uint16, index uint8) (*samples.UserSample, error) {
 	resPath := ResourcesSigCompPath
 	if creator == user {
 		resPath = path.Join(resPath, "genuines")
 	} else {
 		resPath = path.Join(resPath, "forgeries")
 	}
-	if UseFullResources {
+	if full {
 		resPath = path.Join(resPath, "full")
 	} else {
 		resPath = path.Join(resPath, "test")
@@ -96,27 +162,42 @@ func readGPDSUserSample(user uint16, index uint8, forgery bool) (*samples.UserSa
 	}
 }
 
-func ReadUserSample(creator, user uint16, index uint8) (*samples.UserSample, error) {
-	switch ResourceType(*flags.Resources) {
+func ReadUserSample(config Config, creator, user uint16, index uint8) (*samples.UserSample, error) {
+	switch config.Resources {
 	case SigCompResources:
-		return readSigCompUserSample(creator, user, index)
+		return readSigCompUserSample(config.FullResources, creator, user, index)
 	case GPDSResources:
 		forgery := creator != user
 		return readGPDSUserSample(user, index, forgery)
 	case MCYTResources:
 		return readMCYTUserSample(creator, user, index)
 	default:
-		panic(fmt.Sprintf("no such resources: %v", ResourceType(*flags.Resources)))
+		panic(fmt.Sprintf("no such resources: %v", config.Resources))
 	}
 }
 
-func EnrollUser(id uint16, samplesIds []int, rows, cols uint16) signature.UserModel {
-	template := signature.NewModel(rows, cols, nil)
+// EnrollUser builds template of user id from samples samplesIds with model
+// configuration of config. It also builds templates of signature styles if
+// config.Styles is over 1, trains one-class classifier or keeps reference
+// samples if config selects OneClassVerifier or DichotomyVerifier, and sets
+// score normalisations if config.LeaveOneOut or config.ZNormCohort are set,
+// leaving users with fewer than signature.MinLeaveOneOutSamples readable
+// samples without leave-one-out norm. Returned UserModel has nil Model if
+// none of the samples could be read, and nil OneClass if the classifier
+// could not be trained, which is logged.
+func EnrollUser(config Config, id uint16, samplesIds []int) (signature.UserModel, error) {
+	template, err := signature.NewModelFromConfig(config.Model)
+	if err != nil {
+		return signature.UserModel{Id: id}, err
+	}
+	if config.Verifier == DichotomyVerifier && config.Dichotomy == nil {
+		return signature.UserModel{Id: id}, fmt.Errorf("dichotomy verifier needs a classifier")
+	}
 	ok := false
 	userSamples := make([]*samples.UserSample, len(samplesIds))
 	wg := new(sync.WaitGroup)
 	for i, s := range samplesIds {
-		sample, err := ReadUserSample(id, id, uint8(s))
+		sample, err := ReadUserSample(config, id, id, uint8(s))
 		if err != nil {
 			userSamples[i] = nil
 			continue
@@ -126,36 +207,119 @@ func EnrollUser(id uint16, samplesIds []int, rows, cols uint16) signature.UserMo
 		}
 		wg.Add(1)
 		go func(s *samples.UserSample) {
-			s.Preprocess()
+			s.Preprocess(config.Preprocess)
 			wg.Done()
 		}(sample)
 	}
 	wg.Wait()
+	var references []*signature.Model
+	var enrolled []*samples.Sample
 	for i, sample := range userSamples {
 		if sample != nil {
-			template.Extract(sample.Sample(), i+1)
-			sample.Close()
+			if config.Verifier == DichotomyVerifier {
+				references = append(references, config.Dichotomy.Extract(sample.Sample()))
+			}
+			defer sample.Close()
+			if err := template.Extract(sample.Sample(), i+1); err != nil {
+				return signature.UserModel{Id: id}, fmt.Errorf("user %d sample %d: %v", id, i+1, err)
+			}
+			enrolled = append(enrolled, sample.Sample())
 		}
 	}
 	if !ok {
 		return signature.UserModel{
 			Id: id,
+		}, nil
+	}
+	_ = template.Filter()
+	if config.Prior != nil {
+		template.SetVariancePrior(config.Prior)
+	}
+	um := signature.UserModel{
+		Id:         id,
+		Model:      template,
+		References: references,
+	}
+	if config.Styles > 1 {
+		styles, err := template.Styles(config.Styles)
+		if err != nil {
+			return um, fmt.Errorf("user %d: %v", id, err)
+		}
+		for _, style := range styles {
+			if len(styles) > 1 {
+				model, err := signature.NewModelFromConfig(config.Model)
+				if err != nil {
+					return um, fmt.Errorf("user %d: %v", id, err)
+				}
+				for n, i := range style {
+					if err := model.Extract(enrolled[i], n+1); err != nil {
+						return um, fmt.Errorf("user %d style: %v", id, err)
+					}
+				}
+				if err := model.Filter(); err != nil {
+					return um, fmt.Errorf("user %d style: %v", id, err)
+				}
+				if config.Prior != nil {
+					model.SetVariancePrior(config.Prior)
+				}
+				um.Styles = append(um.Styles, model)
+			}
 		}
 	}
-	if !*flags.AreaFilterOff {
-		_ = template.AreaFilter(*flags.AreaFilterFieldThreshold, *flags.AreaFilterRowColThreshold)
+	if config.Verifier == OneClassVerifier {
+		if um.OneClass, err = signature.TrainOneClass(template, config.OneClassNu); err != nil {
+			log.Printf("user %d left without one-class classifier: %v\n", id, err)
+		}
 	}
-	if !*flags.StdFilterOff {
-		_ = template.StdFilter(*flags.StdFilterThreshold)
+	if config.LeaveOneOut && len(enrolled) >= signature.MinLeaveOneOutSamples {
+		if um.Norm, err = signature.LeaveOneOutNorm(config.Model, enrolled, config.Prior); err != nil {
+			return um, fmt.Errorf("user %d: %v", id, err)
+		}
+	}
+	if config.ZNormCohort > 0 {
+		var cohort []*samples.Sample
+		for _, sample := range cohortSamples(config, id) {
+			cohort = append(cohort, sample.Sample())
+			defer sample.Close()
+		}
+		if err := um.SetZNorm(cohort); err != nil {
+			return um, fmt.Errorf("user %d: %v", id, err)
+		}
+	}
+	return um, nil
+}
+
+// cohortSamples reads and preprocesses the first genuine sample of up to
+// config.ZNormCohort users other than id.
+func cohortSamples(config Config, id uint16) []*samples.UserSample {
+	genuineSamplesUsers := GenuineUsers(config)
+	var users []int
+	for user := range genuineSamplesUsers {
+		if user != int(id) {
+			users = append(users, user)
+		}
 	}
-	return signature.UserModel{
-		Id:    id,
-		Model: template,
+	sort.Ints(users)
+	var cohort []*samples.UserSample
+	for _, user := range users {
+		if len(cohort) == config.ZNormCohort {
+			break
+		}
+		sample, err := ReadUserSample(config, uint16(user), uint16(user), uint8(genuineSamplesUsers[user][0]))
+		if err != nil {
+			continue
+		}
+		sample.Preprocess(config.Preprocess)
+		cohort = append(cohort, sample)
 	}
+	return cohort
 }
 
-func EnrollUserSync(id uint16, samplesIds []int, rows, cols uint16, users chan *signature.UserModel) {
-	uf := EnrollUser(id, samplesIds, rows, cols)
+func EnrollUserSync(config Config, id uint16, samplesIds []int, users chan *signature.UserModel) {
+	uf, err := EnrollUser(config, id, samplesIds)
+	if err != nil {
+		panic(err)
+	}
 	users <- &uf
 	return
 }
@@ -165,39 +329,159 @@ type VerificationResult struct {
 	SampleUserId   uint16
 	SuccessCounts  []uint8
 	RejectedCounts []uint8
+	ReviewCounts   []uint8
+	AdaptedCount   uint8
+	// Scores of all verified samples, for training fusion
+	Scores []signature.Score
+	// Values compared with thresholds, for training calibration
+	Values []float64
+	// LLRs and Decisions of Config.Calibration, nil if not set
+	LLRs      []float64
+	Decisions []signature.Decision
+	// Styles of template matched by verified samples, -1 for templates
+	// without styles
+	Styles []int
 }
 
-func scoreSample(id uint16, i uint8, template *signature.UserModel) (signature.Score, error) {
-	sample, err := ReadUserSample(id, template.Id, i)
+// sampleResult is verification of a single sample.
+type sampleResult struct {
+	// score normalised with template and cohort norms
+	score signature.Score
+	// value compared with thresholds
+	value float64
+	// style of template matched, -1 if template has no styles
+	style   int
+	adapted bool
+}
+
+// scoreSample verifies sample i of user id against template. It returns an
+// error only if the sample could not be read or adapted.
+func scoreSample(
+	config Config,
+	id uint16,
+	i uint8,
+	template *signature.UserModel,
+	fusion signature.Fusion,
+	adapt *signature.AdaptPolicy,
+) (sampleResult, error) {
+	sample, err := ReadUserSample(config, id, template.Id, i)
 	if err != nil {
-		return nil, err
+		return sampleResult{}, err
+	}
+	sample.Preprocess(config.Preprocess)
+	defer sample.Close()
+	r := sampleResult{style: -1}
+	var pattern *signature.Model
+	r.score, pattern, r.style = template.ScoreStyle(sample.Sample())
+	if config.Verifier == ScoreVerifier && len(config.TNormCohort) > 0 {
+		if r.score, err = template.TNorm(r.score, sample.Sample(), config.TNormCohort); err != nil {
+			panic(err)
+		}
+	}
+	switch config.Verifier {
+	case OneClassVerifier:
+		if r.style >= 0 && config.Model.Register {
+			// patterns of styles are extracted at the full grid and cover
+			// the template the classifier is trained on, unless registered
+			// to the reference image of the style
+			_, pattern = template.Model.Score(sample.Sample())
+		}
+		r.value, err = template.OneClass.Score(pattern)
+	case DichotomyVerifier:
+		questioned := config.Dichotomy.Extract(sample.Sample())
+		r.value, err = config.Dichotomy.Score(questioned, template.References)
+	default:
+		r.value, err = fusion.Fuse(r.score, template.Model.Config().Weights())
+	}
+	if err != nil {
+		panic(err)
+	}
+	if adapt != nil {
+		// adapted with the value compared with thresholds, after scoring
+		if r.adapted, err = template.Model.Adapt(sample.Sample(), r.value, *adapt); err != nil {
+			return sampleResult{}, err
+		}
 	}
-	sample.Preprocess()
-	score, _ := template.Model.Score(sample.Sample())
-	sample.Close()
-	return score, nil
+	return r, nil
 }
 
+// VerifyUser verifies samples samplesIds of user id against template with
+// every threshold, sending samples within config.ReviewWidth above it to
+// review. Area scores are fused with fusion, MaxFusion if nil, unless
+// config selects another verifier. Templates are adapted with adapt if it is
+// not nil, which only ScoreVerifier without styles supports, with samples
+// whose value is below both adapt threshold and every threshold. Templates
+// without one-class classifier are skipped with OneClassVerifier, no samples
+// are verified against them. It panics if template was enrolled with other
+// preprocessing, see CheckPreprocess.
 func VerifyUser(
+	config Config,
 	id uint16,
 	samplesIds []int,
 	template *signature.UserModel,
 	thresholds []float64,
-	thresholdWeights map[signature.AreaType]float64,
+	fusion signature.Fusion,
+	adapt *signature.AdaptPolicy,
 ) VerificationResult {
+	if fusion == nil {
+		fusion = signature.MaxFusion{}
+	}
+	switch config.Verifier {
+	case OneClassVerifier:
+		if template.OneClass == nil {
+			// classifier could not be trained on enrollment samples
+			samplesIds = nil
+		}
+		adapt = nil
+	case DichotomyVerifier:
+		if config.Dichotomy == nil || len(template.References) == 0 {
+			panic(fmt.Sprintf("user %d has no dichotomy classifier or references", template.Id))
+		}
+		adapt = nil
+	}
+	if err := CheckPreprocess(config, template); err != nil {
+		panic(err)
+	}
+	if len(template.Styles) > 0 {
+		adapt = nil
+	}
+	if adapt != nil {
+		// only samples accepted at every threshold are folded in
+		policy := *adapt
+		for _, t := range thresholds {
+			policy.Threshold = math.Min(policy.Threshold, t)
+		}
+		adapt = &policy
+	}
 	successes := make([]uint8, len(thresholds))
 	rejections := make([]uint8, len(thresholds))
+	reviews := make([]uint8, len(thresholds))
+	var adapted uint8
+	var scores []signature.Score
+	var values, llrs []float64
+	var decisions []signature.Decision
+	var styles []int
 	for _, s := range samplesIds {
-		score, err := scoreSample(id, uint8(s), template)
+		r, err := scoreSample(config, id, uint8(s), template, fusion, adapt)
 		if err == nil {
+			if r.adapted {
+				adapted += 1
+			}
+			scores = append(scores, r.score)
+			values = append(values, r.value)
+			styles = append(styles, r.style)
+			if config.Calibration != nil {
+				llr, decision := config.Calibration.Verify(r.value)
+				llrs = append(llrs, llr)
+				decisions = append(decisions, decision)
+			}
 			for i, t := range thresholds {
-				success, err := score.Check(t, thresholdWeights)
-				if err != nil {
-					panic(err)
-				}
-				if success {
+				switch signature.Decide(r.value, t, t+config.ReviewWidth) {
+				case signature.Accept:
 					successes[i] += 1
-				} else {
+				case signature.Review:
+					reviews[i] += 1
+				default:
 					rejections[i] += 1
 				}
 			}
@@ -208,18 +492,80 @@ func VerifyUser(
 		id,
 		successes,
 		rejections,
+		reviews,
+		adapted,
+		scores,
+		values,
+		llrs,
+		decisions,
+		styles,
+	}
+}
+
+// Verify verifies sample i of user id against template and returns its
+// log-likelihood ratio and decision of config.Calibration.
+func Verify(
+	config Config,
+	id uint16,
+	i uint8,
+	template *signature.UserModel,
+	fusion signature.Fusion,
+) (llr float64, decision signature.Decision, err error) {
+	if config.Calibration == nil {
+		return 0, signature.Reject, fmt.Errorf("verification needs calibration")
+	}
+	if fusion == nil {
+		fusion = signature.MaxFusion{}
+	}
+	if err := CheckPreprocess(config, template); err != nil {
+		return 0, signature.Reject, err
+	}
+	r, err := scoreSample(config, id, i, template, fusion, nil)
+	if err != nil {
+		return 0, signature.Reject, err
 	}
+	llr, decision = config.Calibration.Verify(r.value)
+	return llr, decision, nil
+}
+
+// Identify reads and preprocesses genuine sample i of user id once and ranks
+// templates by how well the sample matches them, see signature.Identify.
+// Only ScoreVerifier supports identification; scores are not T-normalised.
+func Identify(
+	config Config,
+	id uint16,
+	i uint8,
+	templates []*signature.UserModel,
+	fusion signature.Fusion,
+) ([]signature.Candidate, error) {
+	if config.Verifier != ScoreVerifier {
+		return nil, fmt.Errorf("identification needs score verifier")
+	}
+	for _, template := range templates {
+		if err := CheckPreprocess(config, template); err != nil {
+			return nil, err
+		}
+	}
+	sample, err := ReadUserSample(config, id, id, i)
+	if err != nil {
+		return nil, err
+	}
+	sample.Preprocess(config.Preprocess)
+	defer sample.Close()
+	return signature.Identify(sample.Sample(), templates, fusion)
 }
 
 func VerifyUserSync(
+	config Config,
 	id uint16,
 	samplesIds []int,
 	template *signature.UserModel,
 	thresholds []float64,
-	thresholdWeights map[signature.AreaType]float64,
+	fusion signature.Fusion,
+	adapt *signature.AdaptPolicy,
 	results chan *VerificationResult,
 ) {
-	r := VerifyUser(id, samplesIds, template, thresholds, thresholdWeights)
+	r := VerifyUser(config, id, samplesIds, template, thresholds, fusion, adapt)
 	results <- &r
 	return
 }
@@ -227,6 +573,7 @@ func VerifyUserSync(
 type VerificationStat struct {
 	PositiveCounts map[float64]uint16
 	NegativeCounts map[float64]uint16
+	ReviewCounts   map[float64]uint16
 }
 
 func (s *VerificationStat) AcceptanceRate(t float64) float64 {
@@ -237,16 +584,20 @@ func (s *VerificationStat) RejectionRate(t float64) float64 {
 	return float64(s.NegativeCounts[t]) / float64(s.Count(t))
 }
 
+func (s *VerificationStat) ReviewRate(t float64) float64 {
+	return float64(s.ReviewCounts[t]) / float64(s.Count(t))
+}
+
 func (s *VerificationStat) Count(t float64) int {
-	return int(s.PositiveCounts[t] + s.NegativeCounts[t])
+	return int(s.PositiveCounts[t]) + int(s.NegativeCounts[t]) + int(s.ReviewCounts[t])
 }
 
-func GenuineUsers(full bool) map[int][]int {
+func GenuineUsers(config Config) map[int][]int {
 	genuineSamplesUsers := make(map[int][]int)
-	switch ResourceType(*flags.Resources) {
+	switch config.Resources {
 	case SigCompResources:
 		genuinePath := path.Join(ResourcesSigCompPath, "genuines")
-		if full {
+		if config.FullResources {
 			genuinePath = path.Join(genuinePath, "full")
 		} else {
 			genuinePath = path.Join(genuinePath, "test")
@@ -304,7 +655,7 @@ func GenuineUsers(full bool) map[int][]int {
 		}
 		break
 	case GPDSResources:
-		for i := 0; i < *flags.GPDSUsers; i++ {
+		for i := 0; i < config.GPDSUsers; i++ {
 			ss := make([]int, 24)
 			for i := range ss {
 				ss[i] = i + 1
@@ -315,12 +666,12 @@ func GenuineUsers(full bool) map[int][]int {
 	return genuineSamplesUsers
 }
 
-func ForgeryUsers(full bool) map[[2]int][]int {
+func ForgeryUsers(config Config) map[[2]int][]int {
 	forgerySamplesUsers := make(map[[2]int][]int)
-	switch ResourceType(*flags.Resources) {
+	switch config.Resources {
 	case SigCompResources:
 		forgeryPath := path.Join(ResourcesSigCompPath, "forgeries")
-		if full {
+		if config.FullResources {
 			forgeryPath = path.Join(forgeryPath, "full")
 		} else {
 			forgeryPath = path.Join(forgeryPath, "test")
@@ -391,7 +742,7 @@ func ForgeryUsers(full bool) map[[2]int][]int {
 		for i := range ss {
 			ss[i] = i + 1
 		}
-		for i := 0; i < *flags.GPDSUsers; i++ {
+		for i := 0; i < config.GPDSUsers; i++ {
 			forgerySamplesUsers[[2]int{i, i + 1}] = ss
 		}
 	}
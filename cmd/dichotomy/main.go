@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"github.com/radekwlsk/handauth/cmd"
+	"github.com/radekwlsk/handauth/cmd/flags"
+	"github.com/radekwlsk/handauth/signature"
+	"log"
+	"sort"
+	"sync"
+)
+
+const L2Default = 1e-3
+
+var (
+	config      cmd.Config
+	dichotomy   *signature.Dichotomy
+	l2          float64
+	outFileName string
+)
+
+func extract(creator, user uint16, samplesIds []int) []*signature.Model {
+	var models []*signature.Model
+	for _, s := range samplesIds {
+		sample, err := cmd.ReadUserSample(config, creator, user, uint8(s))
+		if err != nil {
+			continue
+		}
+		sample.Preprocess(config.Preprocess)
+		models = append(models, dichotomy.Extract(sample.Sample()))
+		sample.Close()
+	}
+	return models
+}
+
+// dichotomy trains writer-independent classifier on dissimilarity vectors of
+// sample pairs of the development dataset set with -res and writes it to
+// a JSON file that can be passed to other commands with -dichotomy. Pairs of
+// genuine samples of a user are of the same writer, pairs of genuine samples
+// with forgeries of the user and pairs of first genuine samples of two users
+// are of different writers.
+func main() {
+	flag.Float64Var(&l2, "l2", L2Default, "l2 penalty of classifier weights")
+	flag.StringVar(&outFileName, "o", "dichotomy.json", "output file")
+	flag.Parse()
+
+	var err error
+	if config, err = flags.Config(); err != nil {
+		log.Fatal(err)
+	}
+	if dichotomy, err = signature.NewDichotomy(config.Model); err != nil {
+		log.Fatal(err)
+	}
+
+	genuineSamplesUsers := cmd.GenuineUsers(config)
+	forgerySamplesUsers := cmd.ForgeryUsers(config)
+	var users []int
+	for user := range genuineSamplesUsers {
+		users = append(users, user)
+	}
+	sort.Ints(users)
+
+	var same, different [][]float64
+	// first genuine sample of every user, paired with ones of other users
+	firsts := make(map[int]*signature.Model)
+	mutex := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	for _, user := range users {
+		wg.Add(1)
+		go func(user int) {
+			defer wg.Done()
+			id := uint16(user)
+			models := extract(id, id, genuineSamplesUsers[user])
+			if len(models) == 0 {
+				return
+			}
+			var s, d [][]float64
+			for i := range models {
+				for j := i + 1; j < len(models); j++ {
+					s = append(s, dichotomy.Dissimilarity(models[i], models[j]))
+				}
+			}
+			for forgerUser, samplesIds := range forgerySamplesUsers {
+				if forgerUser[1] != user {
+					continue
+				}
+				for _, forgery := range extract(uint16(forgerUser[0]), id, samplesIds) {
+					for _, genuine := range models {
+						d = append(d, dichotomy.Dissimilarity(forgery, genuine))
+					}
+				}
+			}
+			mutex.Lock()
+			same = append(same, s...)
+			different = append(different, d...)
+			firsts[user] = models[0]
+			mutex.Unlock()
+			if flags.Verbose() {
+				log.Printf("Extracted user %03d\n", user)
+			}
+		}(user)
+	}
+	wg.Wait()
+
+	for i, user := range users {
+		for _, other := range users[i+1:] {
+			if firsts[user] != nil && firsts[other] != nil {
+				different = append(different, dichotomy.Dissimilarity(firsts[user], firsts[other]))
+			}
+		}
+	}
+
+	if err := dichotomy.Train(same, different, l2); err != nil {
+		log.Fatal(err)
+	}
+	if err := dichotomy.Write(outFileName); err != nil {
+		log.Fatal(err)
+	}
+	if flags.Verbose() {
+		log.Printf("Trained on %d same and %d different writer pairs\n", len(same), len(different))
+	}
+}
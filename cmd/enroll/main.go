@@ -4,6 +4,7 @@ import (
 	"flag"
 	"github.com/radekwlsk/handauth/cmd"
 	"github.com/radekwlsk/handauth/cmd/flags"
+	"github.com/radekwlsk/handauth/signature"
 	"image"
 	"image/color"
 	"log"
@@ -73,12 +74,21 @@ func main() {
 
 	var beforeCount, midCount, afterCount uint64
 
-	t := true
-	flags.AreaFilterOff = &t
-	flags.StdFilterOff = &t
+	config, err := flags.Config()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.FullResources = true
+	config.Model = signature.DefaultModelConfig(12, 60)
+	config.Model.AreaFilter.Enabled = false
+	config.Model.StdFilter.Enabled = false
+	config.Preprocess.Debug = debug
 
 	for u := 1; u < 31; u++ {
-		um := cmd.EnrollUser(uint16(u), []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 12, 60)
+		um, err := cmd.EnrollUser(config, uint16(u), []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+		if err != nil {
+			panic(err)
+		}
 		beforeCount += um.Model.FeaturesCount()
 		if err := um.Model.AreaFilter(flags.AreaFilterFieldThresholdDefault,
 			flags.AreaFilterRowColThresholdDefault); err != nil {
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/radekwlsk/handauth/cmd"
+	"github.com/radekwlsk/handauth/cmd/flags"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/store"
+	"log"
+	"os"
+	"sort"
+)
+
+const EnrollSamplesDefault = 10
+
+var (
+	user          int
+	creator       int
+	sampleId      int
+	enrollSamples int
+	top           int
+	asJSON        bool
+)
+
+// explain scores a sample against template of a user, or its best matching
+// style, and prints which features deviated from it, as a table or JSON,
+// with area scores normalised like in verification. Template is read from
+// -store if the user is enrolled there, or enrolled from first genuine
+// samples of the user otherwise.
+func main() {
+	flag.IntVar(&user, "user", 1, "user whose template the sample is verified against")
+	flag.IntVar(&creator, "creator", 0, "user who wrote the sample, a forgery if other than -user, -user if 0")
+	flag.IntVar(&sampleId, "sample", 1, "sample index")
+	flag.IntVar(&enrollSamples, "n", EnrollSamplesDefault, "number of genuine samples to enroll user with")
+	flag.IntVar(&top, "top", 10, "features with the highest contributions listed per area, all if 0")
+	flag.BoolVar(&asJSON, "json", false, "print explanation as JSON")
+	flag.Parse()
+
+	config, err := flags.Config()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if creator == 0 {
+		creator = user
+	}
+
+	var template *signature.UserModel
+	if *flags.Store != "" {
+		templates, err := store.Open(*flags.Store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if template, err = templates.Get(uint16(user)); err != nil && flags.Verbose() {
+			log.Println(err)
+		}
+	}
+	if template == nil {
+		samplesIds := cmd.GenuineUsers(config)[user]
+		sort.Ints(samplesIds)
+		if len(samplesIds) > enrollSamples {
+			samplesIds = samplesIds[:enrollSamples]
+		}
+		um, err := cmd.EnrollUser(config, uint16(user), samplesIds)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if um.Model == nil {
+			log.Fatalf("no samples of user %d", user)
+		}
+		template = &um
+	}
+
+	if err := cmd.CheckPreprocess(config, template); err != nil {
+		log.Fatal(err)
+	}
+	sample, err := cmd.ReadUserSample(config, uint16(creator), uint16(user), uint8(sampleId))
+	if err != nil {
+		log.Fatal(err)
+	}
+	sample.Preprocess(config.Preprocess)
+	score, explanation, style := template.Explain(sample.Sample())
+	sample.Close()
+	if flags.Verbose() {
+		log.Printf("Score %v of style %d\n", score, style)
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(explanation)
+	} else {
+		err = explanation.Render(os.Stdout, top)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
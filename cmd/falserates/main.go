@@ -7,46 +7,61 @@ import (
 	"github.com/radekwlsk/handauth/cmd"
 	"github.com/radekwlsk/handauth/cmd/flags"
 	"github.com/radekwlsk/handauth/signature"
-	"github.com/radekwlsk/handauth/signature/features"
+	"github.com/radekwlsk/handauth/store"
 	"log"
 	"math"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
 const SplitDefault = 0.5
+const CalibrationL2Default = 1e-6
 const TestStartTimeFormat = "20060201-150405"
 
 var (
-	split            float64
-	thresholds       []float64
-	thresholdWeights map[signature.AreaType]float64
-	fullResources    bool
-	start            time.Time
-	startString      string
-	outFileName      string
-	outWriter        *csv.Writer
-	configWriter     *csv.Writer
-	workingDir       string
-	testMessage      string
-	genuineStats     cmd.VerificationStat
-	forgeriesStats   cmd.VerificationStat
+	split             float64
+	thresholds        []float64
+	config            cmd.Config
+	start             time.Time
+	startString       string
+	outFileName       string
+	outWriter         *csv.Writer
+	configWriter      *csv.Writer
+	workingDir        string
+	testMessage       string
+	genuineStats      cmd.VerificationStat
+	forgeriesStats    cmd.VerificationStat
+	templates         *store.Store
+	adaptPolicy       *signature.AdaptPolicy
+	adaptedCount      int
+	fusion            signature.Fusion
+	trainFusion       string
+	genuineScores     []signature.Score
+	forgeryScores     []signature.Score
+	genuineValues     []float64
+	forgeryValues     []float64
+	genuineStyles     = map[int]int{}
+	forgeryStyles     = map[int]int{}
+	trainCalibration  string
+	calibrationMethod string
+	calibrationL2     float64
 )
 
 func configRecords() [][]string {
 	var dataset string
-	switch cmd.ResourceType(*flags.Resources) {
+	switch config.Resources {
 	case cmd.GPDSResources:
-		dataset = fmt.Sprintf("GPDS%d", *flags.GPDSUsers)
+		dataset = fmt.Sprintf("GPDS%d", config.GPDSUsers)
 		break
 	case cmd.SigCompResources:
 		var f string
-		if fullResources {
+		if config.FullResources {
 			f = "Full"
 		} else {
 			f = "Test"
@@ -56,41 +71,61 @@ func configRecords() [][]string {
 		dataset = fmt.Sprintf("MCYT")
 		break
 	default:
-		log.Fatalf("no such dataset: %d", cmd.ResourceType(*flags.Resources))
+		log.Fatalf("no such dataset: %d", config.Resources)
 	}
-	config := [][]string{
+	records := [][]string{
 		{"message", testMessage},
 		{"date", start.String()},
-		{"full data", fmt.Sprintf("%v", fullResources)},
+		{"full data", fmt.Sprintf("%v", config.FullResources)},
 		{"dataset", dataset},
-		{"cols", fmt.Sprintf("%d", *flags.Cols)},
-		{"rows", fmt.Sprintf("%d", *flags.Rows)},
+		{"model config", *flags.ConfigFile},
+		{"cols", fmt.Sprintf("%d", config.Model.Cols)},
+		{"rows", fmt.Sprintf("%d", config.Model.Rows)},
 		{"split", fmt.Sprintf("%.2f", split)},
-		{"using area filter", fmt.Sprintf("%v", !*flags.AreaFilterOff)},
-		{"field area min threshold", fmt.Sprintf("%.3f", *flags.AreaFilterFieldThreshold)},
-		{"row/col area min threshold", fmt.Sprintf("%.3f", *flags.AreaFilterRowColThreshold)},
-		{"using std-mean filter", fmt.Sprintf("%v", !*flags.StdFilterOff)},
-		{"std-mean max mean ratio threshold", fmt.Sprintf("%.3f", *flags.StdFilterThreshold)},
+		{"using area filter", fmt.Sprintf("%v", config.Model.AreaFilter.Enabled)},
+		{"field area min threshold", fmt.Sprintf("%.3f", config.Model.AreaFilter.FieldThreshold)},
+		{"row/col area min threshold", fmt.Sprintf("%.3f", config.Model.AreaFilter.RowColThreshold)},
+		{"using std-mean filter", fmt.Sprintf("%v", config.Model.StdFilter.Enabled)},
+		{"std-mean max mean ratio threshold", fmt.Sprintf("%.3f", config.Model.StdFilter.Threshold)},
+		{"templates store", *flags.Store},
+		{"using adaptation", fmt.Sprintf("%v", *flags.Adapt)},
+		{"adaptation threshold", fmt.Sprintf("%.3f", *flags.AdaptThreshold)},
+		{"adaptation window", fmt.Sprintf("%d", *flags.AdaptWindow)},
+		{"fusion", *flags.FusionName},
+		{"fusion model", *flags.FusionFile},
+		{"one-class verifier", fmt.Sprintf("%v", *flags.OneClass)},
+		{"one-class nu", fmt.Sprintf("%.3f", *flags.Nu)},
+		{"dichotomy", *flags.DichotomyFile},
+		{"leave-one-out norm", fmt.Sprintf("%v", *flags.LeaveOneOut)},
+		{"calibration", *flags.CalibrationFile},
+		{"review band width", fmt.Sprintf("%.3f", *flags.ReviewWidth)},
+		{"max styles", fmt.Sprintf("%d", *flags.Styles)},
+		{"z-norm cohort", fmt.Sprintf("%d", *flags.ZNormCohort)},
+		{"t-norm cohort", fmt.Sprintf("%d", len(config.TNormCohort))},
+		{"deskew", fmt.Sprintf("%v", config.Preprocess.Deskew)},
+		{"registration", fmt.Sprintf("%v", config.Model.Register)},
 	}
-	for a, w := range thresholdWeights {
-		config = append(config, []string{fmt.Sprintf("%s weight", a), fmt.Sprintf("%.2f", w)})
-	}
-	for t, f := range signature.AreaFlags {
-		config = append(config, []string{fmt.Sprintf("using %s", t), fmt.Sprintf("%v", f)})
-	}
-	for t, f := range features.FeatureFlags {
-		config = append(config, []string{fmt.Sprintf("using %s", t), fmt.Sprintf("%v", f)})
+	for a, areaConfig := range config.Model.Areas {
+		records = append(records, []string{fmt.Sprintf("%s weight", a), fmt.Sprintf("%.2f", areaConfig.Weight)})
+		for _, t := range areaConfig.Features {
+			records = append(records, []string{fmt.Sprintf("using %s in %s", t, a), "true"})
+		}
 	}
-	return config
+	return records
 }
 
 func main() {
 	flag.Float64Var(&split, "split", SplitDefault, "enroll/test data split ratio")
-	flag.BoolVar(&fullResources, "full", false, "run test on full SigComp dataset if flag res = 0")
 	flag.StringVar(&outFileName, "o", "out.csv", "output file")
 	flag.StringVar(&testMessage, "m", "", "message to be associated with a test")
+	flag.StringVar(&trainFusion, "train-fusion", "", "file to save logistic fusion trained on test scores in")
+	flag.StringVar(&trainCalibration, "train-calibration", "",
+		"file to save calibration trained on test verification values in")
+	flag.StringVar(&calibrationMethod, "calibration-method", signature.LogisticCalibration.String(),
+		"calibration to train: LogisticCalibration or IsotonicCalibration")
+	flag.Float64Var(&calibrationL2, "calibration-l2", CalibrationL2Default,
+		"l2 penalty of the slope of trained LogisticCalibration")
 	flag.Parse()
-	cmd.UseFullResources = fullResources
 
 	start = time.Now()
 	startString = start.Format(TestStartTimeFormat)
@@ -116,8 +151,29 @@ func main() {
 		defer outWriter.Flush()
 	}
 
-	thresholdWeights = flags.ThresholdWeights()
+	{
+		var err error
+		if config, err = flags.Config(); err != nil {
+			log.Fatal(err)
+		}
+	}
 	thresholds = flags.Thresholds()
+	{
+		var err error
+		if adaptPolicy, err = flags.AdaptPolicy(); err != nil {
+			log.Fatal(err)
+		}
+		if fusion, err = flags.Fusion(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *flags.Store != "" {
+		var err error
+		if templates, err = store.Open(*flags.Store); err != nil {
+			panic(err)
+		}
+	}
 
 	{
 		ext := filepath.Ext(outFileName)
@@ -137,13 +193,15 @@ func main() {
 	genuineStats = cmd.VerificationStat{
 		PositiveCounts: map[float64]uint16{},
 		NegativeCounts: map[float64]uint16{},
+		ReviewCounts:   map[float64]uint16{},
 	}
 	forgeriesStats = cmd.VerificationStat{
 		PositiveCounts: map[float64]uint16{},
 		NegativeCounts: map[float64]uint16{},
+		ReviewCounts:   map[float64]uint16{},
 	}
 
-	if *flags.Resources == int(cmd.GPDSResources) {
+	if config.Resources == cmd.GPDSResources {
 		gpds()
 	} else {
 		others()
@@ -154,20 +212,57 @@ func main() {
 		PrintMemUsage()
 	}
 
-	_ = outWriter.Write([]string{"threshold", "FRR", "FAR"})
+	header := []string{"threshold", "FRR", "FAR"}
+	if config.ReviewWidth > 0 {
+		header = append(header, "genuine review", "forgery review")
+	}
+	_ = outWriter.Write(header)
 	for _, t := range thresholds {
-		_ = outWriter.Write([]string{
+		record := []string{
 			fmt.Sprintf("%.2f", t),
 			fmt.Sprintf("%.4f", genuineStats.RejectionRate(t)),
 			fmt.Sprintf("%.4f", forgeriesStats.AcceptanceRate(t)),
-		})
+		}
+		if config.ReviewWidth > 0 {
+			record = append(record,
+				fmt.Sprintf("%.4f", genuineStats.ReviewRate(t)),
+				fmt.Sprintf("%.4f", forgeriesStats.ReviewRate(t)),
+			)
+		}
+		_ = outWriter.Write(record)
+	}
+	if trainFusion != "" {
+		trained, err := signature.TrainLogisticFusion(genuineScores, forgeryScores, 1e-3)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := trained.Write(trainFusion); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if trainCalibration != "" {
+		var method signature.CalibrationMethod
+		if err := method.UnmarshalText([]byte(calibrationMethod)); err != nil {
+			log.Fatal(err)
+		}
+		trained, err := signature.TrainCalibration(method, genuineValues, forgeryValues, calibrationL2)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := trained.Write(trainCalibration); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *flags.Styles > 1 {
+		_ = configWriter.Write([]string{"genuine samples per style", formatStyles(genuineStyles)})
+		_ = configWriter.Write([]string{"forgery samples per style", formatStyles(forgeryStyles)})
 	}
 	_ = configWriter.Write([]string{"total test duration", time.Since(start).String()})
 }
 
 func others() {
-	genuineSamplesUsers := cmd.GenuineUsers(fullResources)
-	forgerySamplesUsers := cmd.ForgeryUsers(fullResources)
+	genuineSamplesUsers := cmd.GenuineUsers(config)
+	forgerySamplesUsers := cmd.ForgeryUsers(config)
 
 	users := map[uint16]*signature.UserModel{}
 	{
@@ -177,13 +272,14 @@ func others() {
 		for user, samples := range genuineSamplesUsers {
 			enrollSplit := math.Ceil(float64(len(samples)) * split)
 			enrollSamples := samples[:int(enrollSplit)]
-			go cmd.EnrollUserSync(uint16(user), enrollSamples, uint16(*flags.Rows), uint16(*flags.Cols), featuresChan)
+			go cmd.EnrollUserSync(config, uint16(user), enrollSamples, featuresChan)
 		}
 
 		for range genuineSamplesUsers {
 			f := <-featuresChan
 			if f.Model != nil {
 				users[f.Id] = f
+				storeUser(f)
 				if *flags.VVerbose {
 					log.Printf("\tEnrolled user %03d\n", f.Id)
 				}
@@ -207,13 +303,15 @@ func others() {
 			samples := genuineSamplesUsers[int(id)]
 			verifySplit := math.Ceil(float64(len(samples)) * split)
 			verifySamples := samples[int(verifySplit):]
-			go cmd.VerifyUserSync(id, verifySamples, user, thresholds, thresholdWeights, genuineResultsChan)
+			go cmd.VerifyUserSync(config, id, verifySamples, user, thresholds, fusion, adaptPolicy,
+				genuineResultsChan)
 		}
 
 		for range users {
 			r := <-genuineResultsChan
 			for i, t := range thresholds {
-				if r.SuccessCounts[i]+r.RejectedCounts[i] > 0 {
+				if r.SuccessCounts[i]+r.RejectedCounts[i]+r.ReviewCounts[i] > 0 {
+					genuineStats.ReviewCounts[t] += uint16(r.ReviewCounts[i])
 					if _, ok := genuineStats.PositiveCounts[t]; ok {
 						genuineStats.PositiveCounts[t] += uint16(r.SuccessCounts[i])
 					} else {
@@ -226,19 +324,30 @@ func others() {
 					}
 				}
 			}
+			adaptedCount += int(r.AdaptedCount)
+			if r.AdaptedCount > 0 {
+				storeAdapted(users[r.TemplateUserId])
+			}
+			genuineScores = append(genuineScores, r.Scores...)
+			genuineValues = append(genuineValues, r.Values...)
+			countStyles(genuineStyles, r.Styles)
 			if *flags.VVerbose {
 				log.Printf("\tVerified user %03d\n", r.TemplateUserId)
 				for i, t := range thresholds {
 					log.Printf(
-						"\t\t%.2f: %d/%d\n",
+						"\t\t%.2f: %d/%d/%d\n",
 						t,
 						r.SuccessCounts[i],
 						r.RejectedCounts[i],
+						r.ReviewCounts[i],
 					)
 				}
 			}
 		}
 		close(genuineResultsChan)
+		if adaptPolicy != nil {
+			_ = configWriter.Write([]string{"adapted samples", fmt.Sprintf("%d", adaptedCount)})
+		}
 
 		elapsed := time.Since(start)
 		_ = configWriter.Write([]string{"genuine verification duration", elapsed.String()})
@@ -254,19 +363,25 @@ func others() {
 
 		for forgerUser, samples := range forgerySamplesUsers {
 			go cmd.VerifyUserSync(
+				config,
 				uint16(forgerUser[0]),
 				samples,
 				users[uint16(forgerUser[1])],
 				thresholds,
-				thresholdWeights,
+				fusion,
+				nil,
 				forgeriesResultsChan,
 			)
 		}
 
 		for range forgerySamplesUsers {
 			r := <-forgeriesResultsChan
+			forgeryScores = append(forgeryScores, r.Scores...)
+			forgeryValues = append(forgeryValues, r.Values...)
+			countStyles(forgeryStyles, r.Styles)
 			for i, t := range thresholds {
-				if r.SuccessCounts[i]+r.RejectedCounts[i] > 0 {
+				if r.SuccessCounts[i]+r.RejectedCounts[i]+r.ReviewCounts[i] > 0 {
+					forgeriesStats.ReviewCounts[t] += uint16(r.ReviewCounts[i])
 					if _, ok := forgeriesStats.PositiveCounts[t]; ok {
 						forgeriesStats.PositiveCounts[t] += uint16(r.SuccessCounts[i])
 					} else {
@@ -287,10 +402,11 @@ func others() {
 				)
 				for i, t := range thresholds {
 					log.Printf(
-						"\t\t%.2f: %d/%d\n",
+						"\t\t%.2f: %d/%d/%d\n",
 						t,
 						r.SuccessCounts[i],
 						r.RejectedCounts[i],
+						r.ReviewCounts[i],
 					)
 				}
 			}
@@ -324,16 +440,29 @@ func gpds() {
 	}
 	wg := new(sync.WaitGroup)
 
-	for i := 1; i <= *flags.GPDSUsers; i++ {
+	for i := 1; i <= config.GPDSUsers; i++ {
 		userId := uint16(i)
 
-		um := cmd.EnrollUser(userId, enrollSamples, uint16(*flags.Rows), uint16(*flags.Cols))
-		wg.Add(1)
-		go func(id uint16, model *signature.UserModel) {
-			r := cmd.VerifyUser(id, verifySamples, model, thresholds, thresholdWeights)
+		um, err := cmd.EnrollUser(config, userId, enrollSamples)
+		if err != nil {
+			panic(err)
+		}
+		if um.Model != nil {
+			storeUser(&um)
+		}
+		verifyGenuine := func(id uint16, model *signature.UserModel) {
+			r := cmd.VerifyUser(config, id, verifySamples, model, thresholds, fusion, adaptPolicy)
+			if r.AdaptedCount > 0 {
+				storeAdapted(model)
+			}
 			genuineStatsMutex.Lock()
+			adaptedCount += int(r.AdaptedCount)
+			genuineScores = append(genuineScores, r.Scores...)
+			genuineValues = append(genuineValues, r.Values...)
+			countStyles(genuineStyles, r.Styles)
 			for i, t := range thresholds {
-				if r.SuccessCounts[i]+r.RejectedCounts[i] > 0 {
+				if r.SuccessCounts[i]+r.RejectedCounts[i]+r.ReviewCounts[i] > 0 {
+					genuineStats.ReviewCounts[t] += uint16(r.ReviewCounts[i])
 					if _, ok := genuineStats.PositiveCounts[t]; ok {
 						genuineStats.PositiveCounts[t] += uint16(r.SuccessCounts[i])
 					} else {
@@ -348,14 +477,25 @@ func gpds() {
 			}
 			genuineStatsMutex.Unlock()
 			wg.Done()
-		}(userId, &um)
+		}
+		wg.Add(1)
+		if adaptPolicy != nil {
+			// adaptation modifies template, verify forgeries against it afterwards
+			verifyGenuine(userId, &um)
+		} else {
+			go verifyGenuine(userId, &um)
+		}
 
 		wg.Add(1)
 		go func(id uint16, model *signature.UserModel) {
-			r := cmd.VerifyUser(id, verifySamples, model, thresholds, thresholdWeights)
+			r := cmd.VerifyUser(config, id, verifySamples, model, thresholds, fusion, nil)
 			forgeriesStatsMutex.Lock()
+			forgeryScores = append(forgeryScores, r.Scores...)
+			forgeryValues = append(forgeryValues, r.Values...)
+			countStyles(forgeryStyles, r.Styles)
 			for i, t := range thresholds {
-				if r.SuccessCounts[i]+r.RejectedCounts[i] > 0 {
+				if r.SuccessCounts[i]+r.RejectedCounts[i]+r.ReviewCounts[i] > 0 {
+					forgeriesStats.ReviewCounts[t] += uint16(r.ReviewCounts[i])
 					if _, ok := forgeriesStats.PositiveCounts[t]; ok {
 						forgeriesStats.PositiveCounts[t] += uint16(r.SuccessCounts[i])
 					} else {
@@ -378,6 +518,59 @@ func gpds() {
 		}
 	}
 	wg.Wait()
+	if adaptPolicy != nil {
+		_ = configWriter.Write([]string{"adapted samples", fmt.Sprintf("%d", adaptedCount)})
+	}
+}
+
+// countStyles counts verified samples by the template style they matched.
+func countStyles(counts map[int]int, styles []int) {
+	for _, style := range styles {
+		counts[style]++
+	}
+}
+
+// formatStyles lists sample counts of styles, "none" counting samples
+// verified against templates without styles.
+func formatStyles(counts map[int]int) string {
+	var styles []int
+	for style := range counts {
+		styles = append(styles, style)
+	}
+	sort.Ints(styles)
+	var parts []string
+	for _, style := range styles {
+		name := "none"
+		if style >= 0 {
+			name = fmt.Sprintf("%d", style)
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d", name, counts[style]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func storeUser(um *signature.UserModel) {
+	if templates == nil {
+		return
+	}
+	if err := templates.Put(um); err != nil {
+		log.Printf("failed to store user %03d: %s\n", um.Id, err)
+	}
+}
+
+// storeAdapted replaces the stored template of the user with its adapted
+// model, keeping everything else stored with it.
+func storeAdapted(um *signature.UserModel) {
+	if templates == nil {
+		return
+	}
+	err := templates.Update(um.Id, func(stored *signature.UserModel) error {
+		stored.Model = um.Model
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to store adapted user %03d: %s\n", um.Id, err)
+	}
 }
 
 func PrintMemUsage() {
@@ -1,9 +1,14 @@
 package flags
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"github.com/radekwlsk/handauth/cmd"
+	"github.com/radekwlsk/handauth/samples"
 	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/features"
+	"github.com/radekwlsk/handauth/store"
 	"strconv"
 )
 
@@ -17,14 +22,18 @@ const (
 	GridThresholdScaleDefault        = 1.0
 	RowThresholdScaleDefault         = 1.0
 	ColThresholdScaleDefault         = 1.0
-	AreaFilterFieldThresholdDefault  = 0.03
-	AreaFilterRowColThresholdDefault = 0.02
-	StdFilterThresholdDefault        = 0.5
+	AreaFilterFieldThresholdDefault  = signature.DefaultAreaFilterFieldThreshold
+	AreaFilterRowColThresholdDefault = signature.DefaultAreaFilterRowColThreshold
+	StdFilterThresholdDefault        = signature.DefaultStdFilterThreshold
+	AdaptThresholdDefault            = 0.5
+	AdaptWindowDefault               = 10
+	TNormCohortDefault               = 20
 )
 
 var (
 	GPDSUsers           = flag.Int("gpds", 100, "amount of GPDS users to use if flag res = 1")
 	Resources           = flag.Int("res", 0, "resources type 0 - SigComp, 1 - GPDS, 2 - MCYT")
+	FullResources       = flag.Bool("full", false, "use full SigComp dataset if flag res = 0")
 	verbose             = flag.Bool("v", false, "print basic messages")
 	VVerbose            = flag.Bool("vv", false, "print additional execution messages")
 	Cols                = flag.Int("cols", ColsDefault, "columns in grid")
@@ -48,6 +57,45 @@ var (
 	StdFilterOff       = flag.Bool("no-std-filter", false, "turn std-mean filter off")
 	StdFilterThreshold = flag.Float64("std-filter", StdFilterThresholdDefault,
 		"std-mean filter max threshold")
+	Mahalanobis = flag.Bool("mahalanobis", false, "score areas with Mahalanobis distance")
+	Shrinkage   = flag.Float64("shrinkage", 0.0,
+		"Mahalanobis covariance shrinkage intensity, estimated from enrollment samples if 0")
+	Deskew   = flag.Bool("deskew", false, "level samples by the principal axis of their ink in preprocessing")
+	Register = flag.Bool("register", false,
+		"register samples to the mean image of templates before extraction")
+	Estimator = flag.String("estimator", features.MeanEstimator.String(),
+		"feature location and scale estimator: MeanEstimator, MedianEstimator or TrimmedEstimator")
+	Trim       = flag.Float64("trim", 0.1, "fraction of observations left out at each end by TrimmedEstimator")
+	PriorFile  = flag.String("prior", "", "JSON variance prior file to set in enrolled templates, none if empty")
+	FusionName = flag.String("fusion", "max",
+		"area scores fusion: max, min, sum, product or logistic")
+	FusionFile = flag.String("fusion-model", "", "JSON logistic fusion model file for -fusion logistic")
+	OneClass   = flag.Bool("one-class", false,
+		"verify with writer-dependent one-class classifiers instead of area score thresholds")
+	Nu = flag.Float64("nu", signature.DefaultOneClassNu,
+		"max fraction of enrollment samples left outside one-class classifier")
+	DichotomyFile = flag.String("dichotomy", "",
+		"JSON writer-independent classifier file to verify with instead of area score thresholds, none if empty")
+	LeaveOneOut = flag.Bool("loo", false,
+		"normalise template scores by leave-one-out scores of enrollment samples")
+	ZNormCohort = flag.Int("znorm", 0,
+		"number of other users to Z-normalise enrolled templates against, none if 0")
+	TNormStore = flag.String("tnorm", "",
+		"directory of templates to T-normalise scores against, none if empty")
+	TNormCohort     = flag.Int("tnorm-cohort", TNormCohortDefault, "max number of T-norm cohort templates")
+	CalibrationFile = flag.String("calibration", "",
+		"JSON calibration file mapping verification values to log-likelihood ratios, none if empty")
+	ReviewWidth = flag.Float64("review", 0,
+		"width of the band above every threshold sent to manual review, none if 0")
+	Styles = flag.Int("styles", 1,
+		"max number of signature styles templates of a user are split into")
+	ConfigFile = flag.String("config", "",
+		"JSON model configuration file, overrides grid size, filter and weight flags if set")
+	Store          = flag.String("store", "", "directory to save enrolled templates in, none if empty")
+	Adapt          = flag.Bool("adapt", false, "fold genuine samples passing adapt threshold into templates")
+	AdaptThreshold = flag.Float64("adapt-threshold", AdaptThresholdDefault,
+		"threshold verification value of a sample has to be below to be folded into template")
+	AdaptWindow = flag.Int("adapt-window", AdaptWindowDefault, "max effective samples count of adapted template")
 )
 
 func Thresholds() []float64 {
@@ -70,6 +118,185 @@ func ThresholdWeights() map[signature.AreaType]float64 {
 	}
 }
 
+// ModelConfig returns model configuration read from ConfigFile if set, or
+// default configuration built from grid size and filter flags otherwise.
+// Deskew flag turns deskewing on in both.
+func ModelConfig() (signature.ModelConfig, error) {
+	if *ConfigFile != "" {
+		config, err := signature.ReadModelConfig(*ConfigFile)
+		config.Deskew = config.Deskew || *Deskew
+		return config, err
+	}
+	config := signature.DefaultModelConfig(uint16(*Rows), uint16(*Cols))
+	config.AreaFilter = signature.AreaFilterConfig{
+		Enabled:         !*AreaFilterOff,
+		FieldThreshold:  *AreaFilterFieldThreshold,
+		RowColThreshold: *AreaFilterRowColThreshold,
+	}
+	config.StdFilter = signature.StdFilterConfig{
+		Enabled:   !*StdFilterOff,
+		Threshold: *StdFilterThreshold,
+	}
+	if *Mahalanobis {
+		config.Scorer = signature.MahalanobisScorer
+	}
+	config.Shrinkage = *Shrinkage
+	config.Register = *Register
+	config.Deskew = *Deskew
+	if err := config.Estimator.UnmarshalText([]byte(*Estimator)); err != nil {
+		return config, err
+	}
+	config.Trim = *Trim
+	for area, weight := range ThresholdWeights() {
+		if areaConfig, ok := config.Areas[area]; ok {
+			areaConfig.Weight = weight
+			config.Areas[area] = areaConfig
+		}
+	}
+	return config, config.Validate()
+}
+
+// Config returns pipeline configuration set by flags.
+func Config() (cmd.Config, error) {
+	model, err := ModelConfig()
+	if err != nil {
+		return cmd.Config{}, err
+	}
+	var prior *signature.VariancePrior
+	if *PriorFile != "" {
+		if prior, err = signature.ReadVariancePrior(*PriorFile); err != nil {
+			return cmd.Config{}, err
+		}
+	}
+	var cohort []*signature.UserModel
+	if *TNormStore != "" {
+		if cohort, err = readCohort(*TNormStore, *TNormCohort, model); err != nil {
+			return cmd.Config{}, err
+		}
+	}
+	if *ReviewWidth < 0 {
+		return cmd.Config{}, fmt.Errorf("review band width has to be non-negative")
+	}
+	var calibration *signature.Calibration
+	if *CalibrationFile != "" {
+		if calibration, err = signature.ReadCalibration(*CalibrationFile); err != nil {
+			return cmd.Config{}, err
+		}
+	}
+	verifier := cmd.ScoreVerifier
+	if *OneClass {
+		verifier = cmd.OneClassVerifier
+	}
+	var dichotomy *signature.Dichotomy
+	if *DichotomyFile != "" {
+		if *OneClass {
+			return cmd.Config{}, fmt.Errorf("-one-class and -dichotomy cannot be used together")
+		}
+		if dichotomy, err = signature.ReadDichotomy(*DichotomyFile); err != nil {
+			return cmd.Config{}, err
+		}
+		verifier = cmd.DichotomyVerifier
+	}
+	if *LeaveOneOut && verifier != cmd.ScoreVerifier {
+		return cmd.Config{}, fmt.Errorf("-loo normalises area scores, which -one-class and -dichotomy do not use")
+	}
+	return cmd.Config{
+		Resources:     cmd.ResourceType(*Resources),
+		FullResources: *FullResources,
+		GPDSUsers:     *GPDSUsers,
+		Preprocess:    samples.PreprocessConfig{Deskew: model.Deskew},
+		Model:         model,
+		Prior:         prior,
+		Verifier:      verifier,
+		OneClassNu:    *Nu,
+		Dichotomy:     dichotomy,
+		LeaveOneOut:   *LeaveOneOut,
+		ZNormCohort:   *ZNormCohort,
+		TNormCohort:   cohort,
+		Calibration:   calibration,
+		ReviewWidth:   *ReviewWidth,
+		Styles:        *Styles,
+	}, nil
+}
+
+// MinTNormCohort is the least number of T-norm cohort templates, so every
+// user is normalised with scores of at least 2 others.
+const MinTNormCohort = 3
+
+// readCohort reads up to n templates of the lowest ids from store dir. They
+// all have to be enrolled with model configuration config, as scores of
+// templates of other grids or features are not comparable.
+func readCohort(dir string, n int, config signature.ModelConfig) ([]*signature.UserModel, error) {
+	if n < MinTNormCohort {
+		return nil, fmt.Errorf("T-norm cohort of %d templates, needs at least %d", n, MinTNormCohort)
+	}
+	want, err := config.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	templates, err := store.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := templates.List()
+	if err != nil {
+		return nil, err
+	}
+	var cohort []*signature.UserModel
+	for _, id := range ids {
+		if len(cohort) == n {
+			break
+		}
+		um, err := templates.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if um.Model == nil {
+			continue
+		}
+		got, err := um.Model.Config().MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(got, want) {
+			return nil, fmt.Errorf("cohort template %d in %s enrolled with another model configuration", id, dir)
+		}
+		cohort = append(cohort, um)
+	}
+	if len(cohort) < MinTNormCohort {
+		return nil, fmt.Errorf("%s has %d templates, T-norm needs at least %d", dir, len(cohort), MinTNormCohort)
+	}
+	return cohort, nil
+}
+
+// Fusion returns area scores fusion chosen with FusionName.
+func Fusion() (signature.Fusion, error) {
+	if *FusionName != "logistic" {
+		return signature.ParseFusion(*FusionName)
+	}
+	if *FusionFile == "" {
+		return nil, fmt.Errorf("logistic fusion needs a model set with -fusion-model")
+	}
+	return signature.ReadLogisticFusion(*FusionFile)
+}
+
+// AdaptPolicy returns template adaptation policy set by flags, nil if
+// adaptation is off. Adapt threshold cannot be above the lowest verification
+// threshold, so only samples accepted at every threshold are folded in.
+func AdaptPolicy() (*signature.AdaptPolicy, error) {
+	if !*Adapt {
+		return nil, nil
+	}
+	if *AdaptThreshold > *minThreshold {
+		return nil, fmt.Errorf("adapt threshold %.3f is above the lowest verification threshold %.3f",
+			*AdaptThreshold, *minThreshold)
+	}
+	return &signature.AdaptPolicy{
+		Threshold: *AdaptThreshold,
+		Window:    *AdaptWindow,
+	}, nil
+}
+
 func Verbose() bool {
 	return *verbose || *VVerbose
 }
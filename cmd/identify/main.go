@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"github.com/radekwlsk/handauth/cmd"
+	"github.com/radekwlsk/handauth/cmd/flags"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/store"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const SplitDefault = 0.5
+const RanksDefault = 5
+
+var (
+	split float64
+	ranks int
+)
+
+// identify enrolls every user of the dataset with the first part of their
+// genuine samples, or reads their templates from -store, identifies the rest
+// of genuine samples among all templates and prints rank-k accuracy, the
+// fraction of samples whose writer is among k best candidates, for k up to
+// -k.
+func main() {
+	flag.Float64Var(&split, "split", SplitDefault, "enroll/test data split ratio")
+	flag.IntVar(&ranks, "k", RanksDefault, "highest rank accuracy is reported for")
+	flag.Parse()
+
+	config, err := flags.Config()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if ranks < 1 {
+		log.Fatal("rank has to be at least 1")
+	}
+	fusion, err := flags.Fusion()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var templates *store.Store
+	if *flags.Store != "" {
+		if templates, err = store.Open(*flags.Store); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	genuineSamplesUsers := cmd.GenuineUsers(config)
+	enrollSamples := map[uint16][]int{}
+	verifySamples := map[uint16][]int{}
+	for user, samples := range genuineSamplesUsers {
+		sort.Ints(samples)
+		enrollSplit := int(math.Ceil(float64(len(samples)) * split))
+		enrollSamples[uint16(user)] = samples[:enrollSplit]
+		verifySamples[uint16(user)] = samples[enrollSplit:]
+	}
+
+	start := time.Now()
+	var users []*signature.UserModel
+	{
+		usersChan := make(chan *signature.UserModel)
+		enrolling := 0
+		for id, samplesIds := range enrollSamples {
+			if templates != nil {
+				if um, err := templates.Get(id); err == nil {
+					users = append(users, um)
+					continue
+				}
+			}
+			enrolling++
+			go cmd.EnrollUserSync(config, id, samplesIds, usersChan)
+		}
+		for i := 0; i < enrolling; i++ {
+			um := <-usersChan
+			if um.Model == nil {
+				continue
+			}
+			users = append(users, um)
+			if templates != nil {
+				if err := templates.Put(um); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+	if flags.Verbose() {
+		log.Printf("Enrolled %d users in %s\n", len(users), time.Since(start))
+	}
+
+	start = time.Now()
+	rankCounts := make([]int, ranks)
+	var count int
+	mutex := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	for _, um := range users {
+		wg.Add(1)
+		go func(id uint16) {
+			defer wg.Done()
+			for _, s := range verifySamples[id] {
+				candidates, err := cmd.Identify(config, id, uint8(s), users, fusion)
+				if err != nil {
+					if *flags.VVerbose {
+						log.Printf("\tuser %03d sample %02d: %v\n", id, s, err)
+					}
+					continue
+				}
+				rank := signature.Rank(candidates, id)
+				if *flags.VVerbose {
+					log.Printf("\tuser %03d sample %02d: rank %d\n", id, s, rank)
+				}
+				mutex.Lock()
+				count++
+				if rank > 0 && rank <= ranks {
+					rankCounts[rank-1]++
+				}
+				mutex.Unlock()
+			}
+		}(um.Id)
+	}
+	wg.Wait()
+	if flags.Verbose() {
+		log.Printf("Identified %d samples in %s\n", count, time.Since(start))
+	}
+	if count == 0 {
+		log.Fatal("no samples identified")
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Comma = '\t'
+	_ = w.Write([]string{"rank", "accuracy"})
+	var correct int
+	for k := 1; k <= ranks; k++ {
+		correct += rankCounts[k-1]
+		_ = w.Write([]string{fmt.Sprintf("%d", k), fmt.Sprintf("%.4f", float64(correct)/float64(count))})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -25,6 +25,7 @@ var (
 	start          time.Time
 	drawMassCenter bool
 	samplesUsers   map[int][]int
+	config         cmd.Config
 )
 
 type mask struct {
@@ -63,11 +64,11 @@ var blackRGBA = color.RGBA{A: 255}
 var redRGBA = color.RGBA{R: 255, A: 255}
 
 func mergedSamples(id int) *image.RGBA {
-	sample, err := cmd.ReadUserSample(uint16(id), uint16(id), uint8(samplesUsers[id][0]))
+	sample, err := cmd.ReadUserSample(config, uint16(id), uint16(id), uint8(samplesUsers[id][0]))
 	if err != nil {
 		panic(err)
 	}
-	sample.Preprocess()
+	sample.Preprocess(config.Preprocess)
 	sampleMat := sample.Sample().Mat()
 	img, err := sampleMat.ToImage()
 	if err != nil {
@@ -88,11 +89,11 @@ func mergedSamples(id int) *image.RGBA {
 
 	var massCenters []image.Point
 	for i, sampleId := range samplesUsers[id] {
-		sample, err := cmd.ReadUserSample(uint16(id), uint16(id), uint8(sampleId))
+		sample, err := cmd.ReadUserSample(config, uint16(id), uint16(id), uint8(sampleId))
 		if err != nil {
 			panic(err)
 		}
-		sample.Preprocess()
+		sample.Preprocess(config.Preprocess)
 		var sampleMat gocv.Mat
 		if drawMassCenter {
 			massCenters = append(massCenters, sample.Sample().CenterOfMass())
@@ -125,7 +126,12 @@ func main() {
 
 	start = time.Now()
 
-	samplesUsers = cmd.GenuineUsers(true)
+	var err error
+	if config, err = flags.Config(); err != nil {
+		log.Fatal(err)
+	}
+	config.FullResources = true
+	samplesUsers = cmd.GenuineUsers(config)
 	if _, ok := samplesUsers[userId]; userId > 0 && !ok {
 		log.Fatalf("No user %03d", userId)
 	}
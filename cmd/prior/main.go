@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"github.com/radekwlsk/handauth/cmd/flags"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/store"
+	"log"
+)
+
+const StrengthDefault = 2.0
+
+var (
+	strength    float64
+	outFileName string
+)
+
+// prior learns variance prior from all templates saved in store and writes
+// it to a JSON file that can be passed to other commands with -prior.
+func main() {
+	flag.Float64Var(&strength, "strength", StrengthDefault, "number of pseudo-samples the prior counts for")
+	flag.StringVar(&outFileName, "o", "prior.json", "output file")
+	flag.Parse()
+
+	if *flags.Store == "" {
+		log.Fatal("templates store has to be set with -store")
+	}
+	templates, err := store.Open(*flags.Store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ids, err := templates.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var models []*signature.Model
+	for _, id := range ids {
+		um, err := templates.Get(id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		models = append(models, um.Model)
+	}
+
+	prior, err := signature.LearnVariancePrior(models, strength)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := prior.Write(outFileName); err != nil {
+		log.Fatal(err)
+	}
+	if flags.Verbose() {
+		log.Printf("Learned prior from %d templates\n", prior.Users)
+	}
+}
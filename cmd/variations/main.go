@@ -24,12 +24,12 @@ import (
 const TestStartTimeFormat = "20060201-150405"
 
 var (
-	fullResources bool
-	addTimestamp  bool
-	userId        int
-	start         time.Time
-	startString   string
-	samplesUsers  map[int][]int
+	config       cmd.Config
+	addTimestamp bool
+	userId       int
+	start        time.Time
+	startString  string
+	samplesUsers map[int][]int
 )
 
 type Grid struct {
@@ -40,7 +40,7 @@ func (g Grid) Min() float64     { return mat.Min(g.Data) }
 func (g Grid) Max() float64     { return mat.Max(g.Data) }
 func (g Grid) Dims() (c, r int) { r, c = g.Data.Dims(); return c, r }
 func (g Grid) Z(c, r int) float64 {
-	return g.Data.At(*flags.Rows-r-1, c)
+	return g.Data.At(int(config.Model.Rows)-r-1, c)
 }
 func (g Grid) X(c int) float64 {
 	_, n := g.Data.Dims()
@@ -130,16 +130,11 @@ func savePlot(p *plot.Plot, dir, filename string) error {
 	return nil
 }
 
-func newTrue() *bool {
-	b := true
-	return &b
-}
-
 func variationBetweenUsers() map[features.FeatureType]*mat.Dense {
 	heatMaps := map[features.FeatureType]*mat.Dense{
-		features.LengthFeatureType:   mat.NewDense(*flags.Rows, *flags.Cols, nil),
-		features.GradientFeatureType: mat.NewDense(*flags.Rows, *flags.Cols, nil),
-		features.HOGFeatureType:      mat.NewDense(*flags.Rows, *flags.Cols, nil),
+		features.LengthFeatureType:   mat.NewDense(int(config.Model.Rows), int(config.Model.Cols), nil),
+		features.GradientFeatureType: mat.NewDense(int(config.Model.Rows), int(config.Model.Cols), nil),
+		features.HOGFeatureType:      mat.NewDense(int(config.Model.Rows), int(config.Model.Cols), nil),
 	}
 
 	var models []*signature.UserModel
@@ -148,8 +143,7 @@ func variationBetweenUsers() map[features.FeatureType]*mat.Dense {
 
 		for user, samples := range samplesUsers {
 			for _, sampleId := range samples {
-				go cmd.EnrollUserSync(uint16(user), []int{sampleId}, uint16(*flags.Rows), uint16(*flags.Cols),
-					featuresChan)
+				go cmd.EnrollUserSync(config, uint16(user), []int{sampleId}, featuresChan)
 			}
 		}
 
@@ -164,8 +158,8 @@ func variationBetweenUsers() map[features.FeatureType]*mat.Dense {
 		close(featuresChan)
 	}
 
-	for r := 0; r < *flags.Rows; r++ {
-		for c := 0; c < *flags.Cols; c++ {
+	for r := 0; r < int(config.Model.Rows); r++ {
+		for c := 0; c < int(config.Model.Cols); c++ {
 			for ftrType := range heatMaps {
 				vector := make([]float64, len(models))
 				for i, model := range models {
@@ -181,16 +175,19 @@ func variationBetweenUsers() map[features.FeatureType]*mat.Dense {
 
 func variationWithinUser(id int) map[features.FeatureType]*mat.Dense {
 	heatMaps := map[features.FeatureType]*mat.Dense{
-		features.LengthFeatureType:   mat.NewDense(*flags.Rows, *flags.Cols, nil),
-		features.GradientFeatureType: mat.NewDense(*flags.Rows, *flags.Cols, nil),
-		features.HOGFeatureType:      mat.NewDense(*flags.Rows, *flags.Cols, nil),
-		//features.MassCenterXFeatureType:  mat.NewDense(*flags.Rows, *flags.Cols, nil),
-		//features.MassCenterYFeatureType:  mat.NewDense(*flags.Rows, *flags.Cols, nil),
+		features.LengthFeatureType:   mat.NewDense(int(config.Model.Rows), int(config.Model.Cols), nil),
+		features.GradientFeatureType: mat.NewDense(int(config.Model.Rows), int(config.Model.Cols), nil),
+		features.HOGFeatureType:      mat.NewDense(int(config.Model.Rows), int(config.Model.Cols), nil),
+		//features.MassCenterXFeatureType:  mat.NewDense(int(config.Model.Rows), int(config.Model.Cols), nil),
+		//features.MassCenterYFeatureType:  mat.NewDense(int(config.Model.Rows), int(config.Model.Cols), nil),
 	}
 
-	userModel := cmd.EnrollUser(uint16(id), samplesUsers[id], uint16(*flags.Rows), uint16(*flags.Cols))
-	for r := 0; r < *flags.Rows; r++ {
-		for c := 0; c < *flags.Cols; c++ {
+	userModel, err := cmd.EnrollUser(config, uint16(id), samplesUsers[id])
+	if err != nil {
+		log.Fatal(err)
+	}
+	for r := 0; r < int(config.Model.Rows); r++ {
+		for c := 0; c < int(config.Model.Cols); c++ {
 			for ftrType := range heatMaps {
 				ftr := userModel.Model.Grid(r, c)[ftrType]
 				heatMaps[ftrType].Set(r, c, ftr.Var())
@@ -204,11 +201,15 @@ func variationWithinUser(id int) map[features.FeatureType]*mat.Dense {
 func main() {
 	flag.IntVar(&userId, "u", -1, "user to generate variation within user's samples, "+
 		"set to negative or leave on default for variation between users")
-	flag.BoolVar(&fullResources, "full", false, "run test on full dataset")
 	flag.BoolVar(&addTimestamp, "time", false, "add test time to filenames")
 	flag.Parse()
-	flags.AreaFilterOff = newTrue()
-	flags.StdFilterOff = newTrue()
+
+	var err error
+	if config, err = flags.Config(); err != nil {
+		log.Fatal(err)
+	}
+	config.Model.AreaFilter.Enabled = false
+	config.Model.StdFilter.Enabled = false
 
 	start = time.Now()
 	if addTimestamp {
@@ -216,9 +217,11 @@ func main() {
 	}
 
 	if userId > 0 {
-		fullResources = true
+		config.FullResources = true
 	}
-	samplesUsers = cmd.GenuineUsers(fullResources)
+	samplesUsers = cmd.GenuineUsers(config)
+	// samples are read from the full dataset whichever users are listed
+	config.FullResources = true
 	if _, ok := samplesUsers[userId]; userId > 0 && !ok {
 		log.Fatal("No such user")
 	}
@@ -267,7 +270,7 @@ func main() {
 				log.Println(fmt.Sprintf("empty matrix %s of values %f", ft, mat.Min(hm)))
 				continue
 			}
-			filename := ft.String() + "_grid_" + strconv.Itoa(*flags.Resources) + ".dat"
+			filename := ft.String() + "_grid_" + strconv.Itoa(int(config.Resources)) + ".dat"
 			workingDir, _ := os.Getwd()
 			file, err := os.Create(path.Join(workingDir, "res", filename))
 			if err != nil {
@@ -0,0 +1,51 @@
+package samples
+
+import (
+	"gocv.io/x/gocv"
+	"image"
+	"image/color"
+	"math"
+)
+
+// MaxSkewAngle bounds skew corrected by Deskew, in radians. Principal axis
+// of a steeper sample tells more about its shape than about its slant.
+const MaxSkewAngle = math.Pi / 4
+
+// Deskew estimates skew of the binarised sample as the angle of the
+// principal axis of its ink, see Pose, and rotates the sample around its
+// center to level it. The sample grows so no ink is cut off; Crop removes
+// the margin. Samples skewed by more than MaxSkewAngle are not rotated, but
+// their estimated skew is kept like of any other, see Skew.
+func (sample *Sample) Deskew() {
+	defer sample.Update()
+	p, ok := sample.Pose()
+	if !ok {
+		sample.skew = 0
+		return
+	}
+	sample.skew = p.Angle
+	if math.Abs(p.Angle) > MaxSkewAngle {
+		return
+	}
+
+	w, h := float64(sample.Width()), float64(sample.Height())
+	cos, sin := math.Cos(-p.Angle), math.Sin(-p.Angle)
+	width := math.Ceil(math.Abs(w*cos) + math.Abs(h*sin))
+	height := math.Ceil(math.Abs(w*sin) + math.Abs(h*cos))
+
+	m := gocv.NewMatWithSize(2, 3, gocv.MatTypeCV64F)
+	defer m.Close()
+	m.SetDoubleAt(0, 0, cos)
+	m.SetDoubleAt(0, 1, -sin)
+	m.SetDoubleAt(0, 2, width/2-(cos*w/2-sin*h/2))
+	m.SetDoubleAt(1, 0, sin)
+	m.SetDoubleAt(1, 1, cos)
+	m.SetDoubleAt(1, 2, height/2-(sin*w/2+cos*h/2))
+
+	dst := gocv.NewMat()
+	gocv.WarpAffineWithParams(sample.mat, &dst, m, image.Pt(int(width), int(height)),
+		gocv.InterpolationNearestNeighbor, gocv.BorderConstant, color.RGBA{A: 255})
+
+	_ = sample.mat.Close()
+	sample.mat = dst
+}
@@ -0,0 +1,201 @@
+package samples
+
+import (
+	"fmt"
+	"gocv.io/x/gocv"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ReferenceWidth is the width reference images are kept at.
+const ReferenceWidth = 100
+
+const (
+	// MaxRegisterAngle bounds rotation of registered samples, in radians.
+	MaxRegisterAngle = math.Pi / 6
+	// MaxRegisterScale bounds scaling of registered samples, both up and down.
+	MaxRegisterScale = 1.5
+)
+
+// Reference is the mean image of samples registered to each other, see
+// Sample.Register, kept downscaled to ReferenceWidth.
+type Reference struct {
+	// Width and Height are the size of samples registered to the reference.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	Cols   int `json:"cols"`
+	Rows   int `json:"rows"`
+	// Pixels are mean ink of samples, from 0 to 1, row after row.
+	Pixels []float64 `json:"pixels"`
+}
+
+// NewReference returns empty reference image of samples of given size.
+func NewReference(width, height int) (*Reference, error) {
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("reference of %dx%d samples", width, height)
+	}
+	rows := int(math.Max(1, math.Round(float64(height*ReferenceWidth)/float64(width))))
+	return &Reference{
+		Width:  width,
+		Height: height,
+		Cols:   ReferenceWidth,
+		Rows:   rows,
+		Pixels: make([]float64, rows*ReferenceWidth),
+	}, nil
+}
+
+func (ref *Reference) Validate() error {
+	if ref.Width < 1 || ref.Height < 1 || ref.Cols < 1 || ref.Rows < 1 {
+		return fmt.Errorf("reference of %dx%d samples kept at %dx%d", ref.Width, ref.Height, ref.Cols, ref.Rows)
+	}
+	if len(ref.Pixels) != ref.Rows*ref.Cols {
+		return fmt.Errorf("reference of %dx%d has %d pixels", ref.Cols, ref.Rows, len(ref.Pixels))
+	}
+	return nil
+}
+
+// Add adds sample of the size of reference samples to the mean image as
+// n-th sample, weighted 1/n.
+func (ref *Reference) Add(sample *Sample, n int) error {
+	if sample.Width() != ref.Width || sample.Height() != ref.Height {
+		return fmt.Errorf("cannot add %dx%d sample to reference of %dx%d samples",
+			sample.Width(), sample.Height(), ref.Width, ref.Height)
+	}
+	if n < 1 {
+		n = 1
+	}
+	small := gocv.NewMat()
+	defer small.Close()
+	gocv.Resize(sample.mat, &small, image.Pt(ref.Cols, ref.Rows), 0.0, 0.0, gocv.InterpolationArea)
+
+	w := 1.0 / float64(n)
+	for r := 0; r < ref.Rows; r++ {
+		for c := 0; c < ref.Cols; c++ {
+			i := r*ref.Cols + c
+			ref.Pixels[i] += w * (float64(small.GetUCharAt(r, c))/255.0 - ref.Pixels[i])
+		}
+	}
+	return nil
+}
+
+// Merge combines reference of nSelf samples with other of nOther samples.
+func (ref *Reference) Merge(other *Reference, nSelf, nOther int) error {
+	if err := ref.CheckMerge(other); err != nil {
+		return err
+	}
+	w := float64(nOther) / float64(nSelf+nOther)
+	for i, p := range other.Pixels {
+		ref.Pixels[i] += w * (p - ref.Pixels[i])
+	}
+	return nil
+}
+
+// CheckMerge returns the error Merge would fail with, without changing ref.
+func (ref *Reference) CheckMerge(other *Reference) error {
+	if ref.Width != other.Width || ref.Height != other.Height || len(ref.Pixels) != len(other.Pixels) {
+		return fmt.Errorf("cannot merge reference of %dx%d samples with reference of %dx%d samples",
+			ref.Width, ref.Height, other.Width, other.Height)
+	}
+	return nil
+}
+
+// Pose is the placement of ink in an image: its centroid, angle of its
+// principal axis in radians, clockwise as y grows down, and spread, the
+// radius of gyration around the centroid.
+type Pose struct {
+	X      float64
+	Y      float64
+	Angle  float64
+	Spread float64
+}
+
+// poseFromMoments returns pose of ink with raw image moments, false if the
+// image has no ink.
+func poseFromMoments(m00, m10, m01, m20, m11, m02 float64) (Pose, bool) {
+	if !(m00 > 0) {
+		return Pose{}, false
+	}
+	x, y := m10/m00, m01/m00
+	mu20 := m20/m00 - x*x
+	mu02 := m02/m00 - y*y
+	mu11 := m11/m00 - x*y
+	p := Pose{
+		X:      x,
+		Y:      y,
+		Angle:  0.5 * math.Atan2(2*mu11, mu20-mu02),
+		Spread: math.Sqrt(math.Max(0, mu20+mu02)),
+	}
+	return p, p.Spread > 0
+}
+
+// Pose returns pose of the mean image in coordinates of reference samples,
+// false if it has no ink.
+func (ref *Reference) Pose() (Pose, bool) {
+	sx := float64(ref.Width) / float64(ref.Cols)
+	sy := float64(ref.Height) / float64(ref.Rows)
+	var m00, m10, m01, m20, m11, m02 float64
+	for r := 0; r < ref.Rows; r++ {
+		y := (float64(r) + 0.5) * sy
+		for c := 0; c < ref.Cols; c++ {
+			x := (float64(c) + 0.5) * sx
+			p := ref.Pixels[r*ref.Cols+c]
+			m00 += p
+			m10 += p * x
+			m01 += p * y
+			m20 += p * x * x
+			m11 += p * x * y
+			m02 += p * y * y
+		}
+	}
+	return poseFromMoments(m00, m10, m01, m20, m11, m02)
+}
+
+// Pose returns pose of ink of the sample, false if it has none.
+func (sample *Sample) Pose() (Pose, bool) {
+	m := gocv.Moments(sample.mat, true)
+	return poseFromMoments(m["m00"], m["m10"], m["m01"], m["m20"], m["m11"], m["m02"])
+}
+
+// Register returns a copy of the sample of the size of reference samples,
+// warped so its ink has the pose of the mean image: moved to the same
+// centroid, rotated to the same principal axis and scaled to the same spread.
+// Rotation and scale are bounded by MaxRegisterAngle and MaxRegisterScale.
+// Samples are only resized if either has no ink.
+func (sample *Sample) Register(ref *Reference) *Sample {
+	registered := &Sample{mat: gocv.NewMat(), skew: sample.skew}
+	defer registered.Update()
+
+	size := image.Pt(ref.Width, ref.Height)
+	sp, ok := sample.Pose()
+	rp, refOk := ref.Pose()
+	if !ok || !refOk {
+		gocv.Resize(sample.mat, &registered.mat, size, 0.0, 0.0, gocv.InterpolationNearestNeighbor)
+		return registered
+	}
+
+	angle := rp.Angle - sp.Angle
+	// principal axes have no direction
+	for angle > math.Pi/2 {
+		angle -= math.Pi
+	}
+	for angle <= -math.Pi/2 {
+		angle += math.Pi
+	}
+	angle = math.Max(-MaxRegisterAngle, math.Min(MaxRegisterAngle, angle))
+	scale := math.Max(1/MaxRegisterScale, math.Min(MaxRegisterScale, rp.Spread/sp.Spread))
+
+	cos, sin := scale*math.Cos(angle), scale*math.Sin(angle)
+	m := gocv.NewMatWithSize(2, 3, gocv.MatTypeCV64F)
+	defer m.Close()
+	m.SetDoubleAt(0, 0, cos)
+	m.SetDoubleAt(0, 1, -sin)
+	m.SetDoubleAt(0, 2, rp.X-(cos*sp.X-sin*sp.Y))
+	m.SetDoubleAt(1, 0, sin)
+	m.SetDoubleAt(1, 1, cos)
+	m.SetDoubleAt(1, 2, rp.Y-(sin*sp.X+cos*sp.Y))
+
+	gocv.WarpAffineWithParams(sample.mat, &registered.mat, m, size,
+		gocv.InterpolationNearestNeighbor, gocv.BorderConstant, color.RGBA{A: 255})
+	return registered
+}
@@ -21,14 +21,25 @@ const (
 	TargetWidth = 500.0
 )
 
-var Debug = false
 var logger = log.New(os.Stdout, "[sample] ", log.Lshortfile+log.Ltime)
 
+// PreprocessConfig controls Sample.Preprocess.
+type PreprocessConfig struct {
+	// Ratio of resized sample, original ratio is kept if 0.
+	Ratio float64
+	// Deskew levels samples before cropping, see Sample.Deskew.
+	Deskew bool
+	// Debug saves the sample in res directory after every step.
+	Debug bool
+}
+
 type Sample struct {
 	mat    gocv.Mat
 	height uint16
 	width  uint16
 	ratio  float64
+	// skew is the angle the sample was leveled by, see Deskew
+	skew float64
 }
 
 func NewSample(filename string) (*Sample, error) {
@@ -42,9 +53,6 @@ func NewSample(filename string) (*Sample, error) {
 	if s.Empty() {
 		return nil, fmt.Errorf("could not read file %s", filename)
 	}
-	if Debug {
-		logger.Printf("read sample from %s: %#v\n", filename, s)
-	}
 	return s, err
 }
 
@@ -59,6 +67,7 @@ func (sample *Sample) Copy() *Sample {
 		height: sample.height,
 		width:  sample.width,
 		ratio:  sample.ratio,
+		skew:   sample.skew,
 	}
 }
 
@@ -86,6 +95,13 @@ func (sample *Sample) Ratio() float64 {
 	return sample.ratio
 }
 
+// Skew returns angle of the principal axis of the sample estimated by
+// Deskew, in radians, clockwise as y grows down. It is 0 if the sample was
+// not deskewed.
+func (sample *Sample) Skew() float64 {
+	return sample.skew
+}
+
 func (sample *Sample) Area() int {
 	return sample.mat.Total()
 }
@@ -99,29 +115,39 @@ func (sample *Sample) read(name string) error {
 	return nil
 }
 
-func (sample *Sample) Preprocess(ratio float64) {
+func (sample *Sample) Preprocess(config PreprocessConfig) {
+	if config.Debug {
+		logger.Printf("preprocess sample %#v\n", sample)
+	}
 	sample.Normalize()
-	if Debug {
+	if config.Debug {
 		sample.Save("res", "normalized", false)
 	}
 	sample.Foreground()
-	if Debug {
+	if config.Debug {
 		sample.Save("res", "foreground", false)
 	}
+	if config.Deskew {
+		sample.Deskew()
+		if config.Debug {
+			logger.Printf("skew %.2f deg\n", sample.skew*180/math.Pi)
+			sample.Save("res", "deskewed", false)
+		}
+	}
 	sample.Crop()
-	if Debug {
+	if config.Debug {
 		sample.Save("res", "cropped", false)
 	}
-	sample.Resize(TargetWidth, ratio)
-	if Debug {
+	sample.Resize(TargetWidth, config.Ratio)
+	if config.Debug {
 		sample.Save("res", "resized", false)
 	}
 	sample.ZhangSuen()
-	if Debug {
+	if config.Debug {
 		sample.Save("res", "thinned", false)
 	}
 	//sample.ToLines()
-	//if Debug { sample.Save("res", "lines", false) }
+	//if config.Debug { sample.Save("res", "lines", false) }
 }
 
 func (sample *Sample) Update() {
@@ -328,9 +354,6 @@ func (sample *Sample) Save(dir, filename string, show bool) string {
 	if err := f.Close(); err != nil {
 		log.Println(err)
 	}
-	if Debug {
-		log.Printf("saved sample %#v as %s", sample, f.Name())
-	}
 	if show {
 		command := "display"
 		cmd := exec.Command(command, filepath)
@@ -28,8 +28,8 @@ func (s *UserSample) Copy() *UserSample {
 	}
 }
 
-func (s *UserSample) Preprocess() {
-	s.sample.Preprocess(0.0)
+func (s *UserSample) Preprocess(config PreprocessConfig) {
+	s.sample.Preprocess(config)
 }
 
 func (s *UserSample) Save(dir, filename string, show bool) {
@@ -0,0 +1,64 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/samples"
+	"math"
+	"time"
+)
+
+// AdaptPolicy controls folding verified samples back into a template with
+// Model.Adapt.
+type AdaptPolicy struct {
+	// Threshold the verification value of a sample has to be below to be
+	// folded in. The value is the one compared with verification thresholds,
+	// normalised and fused like for Decide, so a threshold not above the
+	// strictest verification threshold only folds in samples accepted at
+	// every one of them.
+	Threshold float64
+	// Window bounds effective number of samples in the template. Once
+	// reached, every adapted sample is weighted 1/Window and older samples
+	// decay exponentially. Templates with half-life set decay by time instead
+	// and adapt with the current time.
+	Window int
+}
+
+func (p AdaptPolicy) validate() error {
+	if !(p.Threshold > 0) || math.IsInf(p.Threshold, 1) {
+		return fmt.Errorf("adapt threshold has to be positive and finite, got %f", p.Threshold)
+	}
+	if p.Window < 2 {
+		return fmt.Errorf("adapt window has to be at least 2, got %d", p.Window)
+	}
+	return nil
+}
+
+// Adapt extracts sample into the model only if value, the verification value
+// of the sample against the model, is below policy threshold. It returns
+// whether the model was updated.
+func (model *Model) Adapt(sample *samples.Sample, value float64, policy AdaptPolicy) (bool, error) {
+	if err := policy.validate(); err != nil {
+		return false, err
+	}
+	if model.samples < 1 {
+		return false, fmt.Errorf("model samples count unknown, re-enroll to adapt")
+	}
+	if !(value < policy.Threshold) {
+		return false, nil
+	}
+
+	if model.halfLife > 0 {
+		if err := model.ExtractAt(sample, time.Now()); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	n := model.samples + 1
+	if n > policy.Window {
+		n = policy.Window
+	}
+	if err := model.Extract(sample, n); err != nil {
+		return false, err
+	}
+	return true, nil
+}
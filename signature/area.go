@@ -24,6 +24,10 @@ const (
 	GridAreaType
 )
 
+func (t AreaType) Mask() features.AreaMask {
+	return 1 << uint(t)
+}
+
 type GridFeatureMap map[[2]int]features.FeatureMap
 type RowFeatureMap map[int]features.FeatureMap
 type ColFeatureMap map[int]features.FeatureMap
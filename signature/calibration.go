@@ -0,0 +1,253 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/signature/classifier"
+	"io"
+	"math"
+	"sort"
+)
+
+// CalibrationMethod selects how Calibration maps verification scores to
+// log-likelihood ratios.
+type CalibrationMethod int
+
+const (
+	// LogisticCalibration fits LLR linear in the score with logistic
+	// regression.
+	LogisticCalibration CalibrationMethod = iota
+	// IsotonicCalibration fits LLR non-increasing in the score with pool
+	// adjacent violators, interpolated linearly between pooled blocks.
+	IsotonicCalibration
+)
+
+func (m CalibrationMethod) String() string {
+	switch m {
+	case LogisticCalibration:
+		return "LogisticCalibration"
+	case IsotonicCalibration:
+		return "IsotonicCalibration"
+	default:
+		return fmt.Sprintf("CalibrationMethod(%d)", int(m))
+	}
+}
+
+func (m CalibrationMethod) MarshalText() ([]byte, error) {
+	if m < LogisticCalibration || m > IsotonicCalibration {
+		return nil, fmt.Errorf("unknown calibration method %d", int(m))
+	}
+	return []byte(m.String()), nil
+}
+
+func (m *CalibrationMethod) UnmarshalText(text []byte) error {
+	for t := LogisticCalibration; t <= IsotonicCalibration; t++ {
+		if t.String() == string(text) {
+			*m = t
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown calibration method %q", string(text))
+}
+
+// Calibration maps verification scores, values compared with thresholds,
+// to log-likelihood ratios of the sample being genuine rather than a forgery,
+// log p(score | genuine) / p(score | forgery). Training class proportions are
+// removed, so LLR 0 means the score is equally likely for both. In JSON it
+// reads:
+//
+//	{"method": "LogisticCalibration", "slope": -3.1, "intercept": 4.2, "threshold": 0}
+//	{"method": "IsotonicCalibration", "scores": [0.4, 0.9, 1.6], "llrs": [2.3, 0.1, -2.0], "threshold": 0}
+type Calibration struct {
+	Method    CalibrationMethod `json:"method"`
+	Slope     float64           `json:"slope,omitempty"`
+	Intercept float64           `json:"intercept,omitempty"`
+	Scores    []float64         `json:"scores,omitempty"`
+	LLRs      []float64         `json:"llrs,omitempty"`
+	// Threshold is the LLR a sample has to reach to be accepted.
+	Threshold float64 `json:"threshold"`
+	// Review is the width of the band of LLRs below Threshold sent to
+	// manual review instead of being rejected.
+	Review float64 `json:"review,omitempty"`
+}
+
+// TrainCalibration fits calibration of given method to scores of genuine
+// samples and forgeries, usually from a development dataset. NaN and
+// infinite scores are skipped. l2 is the penalty of the slope of
+// LogisticCalibration.
+func TrainCalibration(method CalibrationMethod, genuine, forgery []float64, l2 float64) (*Calibration, error) {
+	genuine, forgery = finite(genuine), finite(forgery)
+	if len(genuine) == 0 || len(forgery) == 0 {
+		return nil, fmt.Errorf("calibration needs genuine and forgery scores, got %d and %d",
+			len(genuine), len(forgery))
+	}
+	priorLogOdds := math.Log(float64(len(genuine)) / float64(len(forgery)))
+	c := &Calibration{Method: method}
+	switch method {
+	case LogisticCalibration:
+		var x [][]float64
+		var y []bool
+		for _, s := range genuine {
+			x = append(x, []float64{s})
+			y = append(y, true)
+		}
+		for _, s := range forgery {
+			x = append(x, []float64{s})
+			y = append(y, false)
+		}
+		logistic, err := classifier.TrainLogistic(x, y, l2)
+		if err != nil {
+			return nil, err
+		}
+		c.Slope = logistic.Weights[0]
+		c.Intercept = logistic.Bias - priorLogOdds
+	case IsotonicCalibration:
+		c.Scores, c.LLRs = isotonic(genuine, forgery)
+		for i := range c.LLRs {
+			c.LLRs[i] -= priorLogOdds
+		}
+	default:
+		return nil, fmt.Errorf("unknown calibration method %d", int(method))
+	}
+	return c, nil
+}
+
+func finite(xs []float64) []float64 {
+	var fs []float64
+	for _, x := range xs {
+		if !math.IsNaN(x) && !math.IsInf(x, 0) {
+			fs = append(fs, x)
+		}
+	}
+	return fs
+}
+
+// isotonic returns mean scores of blocks pooled by pool adjacent violators,
+// so the proportion of genuine samples does not increase with the score, and
+// log-odds of the proportions. One pseudo-sample of each class spread over
+// the blocks keeps log-odds finite.
+func isotonic(genuine, forgery []float64) (scores, logOdds []float64) {
+	type block struct {
+		sum, genuine, n float64
+	}
+	type point struct {
+		score   float64
+		genuine bool
+	}
+	var points []point
+	for _, s := range genuine {
+		points = append(points, point{s, true})
+	}
+	for _, s := range forgery {
+		points = append(points, point{s, false})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].score < points[j].score })
+
+	var blocks []block
+	for _, p := range points {
+		b := block{sum: p.score, n: 1}
+		if p.genuine {
+			b.genuine = 1
+		}
+		blocks = append(blocks, b)
+		for len(blocks) > 1 {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			// pool while the later block has higher or equal proportion
+			// of genuine samples or the same score
+			if last.genuine*prev.n < prev.genuine*last.n && last.sum/last.n != prev.sum/prev.n {
+				break
+			}
+			blocks = blocks[:len(blocks)-1]
+			blocks[len(blocks)-1] = block{prev.sum + last.sum, prev.genuine + last.genuine, prev.n + last.n}
+		}
+	}
+	total := float64(len(points))
+	for _, b := range blocks {
+		share := b.n / total
+		g := b.genuine + share
+		f := b.n - b.genuine + share
+		scores = append(scores, b.sum/b.n)
+		logOdds = append(logOdds, math.Log(g/f))
+	}
+	return scores, logOdds
+}
+
+// LLR returns log-likelihood ratio of verification score.
+func (c *Calibration) LLR(score float64) float64 {
+	if math.IsNaN(score) {
+		return math.NaN()
+	}
+	switch c.Method {
+	case LogisticCalibration:
+		return c.Slope*score + c.Intercept
+	case IsotonicCalibration:
+		n := len(c.Scores)
+		if n == 0 {
+			return math.NaN()
+		}
+		i := sort.SearchFloat64s(c.Scores, score)
+		switch {
+		case i == 0:
+			return c.LLRs[0]
+		case i == n:
+			return c.LLRs[n-1]
+		}
+		t := (score - c.Scores[i-1]) / (c.Scores[i] - c.Scores[i-1])
+		return c.LLRs[i-1] + t*(c.LLRs[i]-c.LLRs[i-1])
+	default:
+		return math.NaN()
+	}
+}
+
+// Verify returns log-likelihood ratio of verification score and accepts the
+// sample if it reaches the threshold, or sends it to review if it is within
+// the review band below. NaN scores are rejected.
+func (c *Calibration) Verify(score float64) (llr float64, decision Decision) {
+	llr = c.LLR(score)
+	switch {
+	case llr >= c.Threshold:
+		return llr, Accept
+	case llr >= c.Threshold-c.Review:
+		return llr, Review
+	default:
+		return llr, Reject
+	}
+}
+
+func (c *Calibration) validate() error {
+	if _, err := c.Method.MarshalText(); err != nil {
+		return err
+	}
+	if c.Review < 0 {
+		return fmt.Errorf("negative review band")
+	}
+	if len(c.Scores) != len(c.LLRs) {
+		return fmt.Errorf("calibration has %d scores and %d llrs", len(c.Scores), len(c.LLRs))
+	}
+	if c.Method == IsotonicCalibration && len(c.Scores) == 0 {
+		return fmt.Errorf("isotonic calibration has no scores")
+	}
+	if !sort.Float64sAreSorted(c.Scores) {
+		return fmt.Errorf("calibration scores are not sorted")
+	}
+	return nil
+}
+
+func ReadCalibration(filename string) (*Calibration, error) {
+	c := new(Calibration)
+	if err := readJSON(filename, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func LoadCalibration(r io.Reader) (*Calibration, error) {
+	c := new(Calibration)
+	if err := loadJSON(r, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Calibration) Write(filename string) error {
+	return writeJSON(filename, c)
+}
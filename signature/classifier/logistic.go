@@ -0,0 +1,113 @@
+package classifier
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math"
+)
+
+const (
+	logisticIterations = 100
+	logisticTolerance  = 1e-8
+)
+
+// Logistic is a binary logistic regression model. Probability of the
+// positive class is 1 / (1 + exp(-(Weights·x + Bias))).
+type Logistic struct {
+	Weights []float64 `json:"weights"`
+	Bias    float64   `json:"bias"`
+}
+
+// TrainLogistic fits logistic regression to samples x labelled y, true for
+// the positive class, with Newton-Raphson iterations. Weights, but not the
+// bias, are penalised with l2 times their squared norm, which keeps them
+// finite when classes are separable.
+func TrainLogistic(x [][]float64, y []bool, l2 float64) (*Logistic, error) {
+	if len(x) == 0 || len(x) != len(y) {
+		return nil, fmt.Errorf("got %d samples and %d labels", len(x), len(y))
+	}
+	if l2 < 0 {
+		return nil, fmt.Errorf("negative l2 penalty")
+	}
+	d := len(x[0])
+	for _, xi := range x {
+		if len(xi) != d {
+			return nil, fmt.Errorf("samples of %d and %d dimensions", d, len(xi))
+		}
+	}
+
+	// parameters are weights followed by bias
+	theta := make([]float64, d+1)
+	for it := 0; it < logisticIterations; it++ {
+		grad := mat.NewVecDense(d+1, nil)
+		hess := mat.NewSymDense(d+1, nil)
+		for i, xi := range x {
+			p := sigmoid(dot(theta[:d], xi) + theta[d])
+			r := p
+			if y[i] {
+				r -= 1
+			}
+			w := p * (1 - p)
+			for j := 0; j <= d; j++ {
+				xj := 1.0
+				if j < d {
+					xj = xi[j]
+				}
+				grad.SetVec(j, grad.AtVec(j)+r*xj)
+				for k := j; k <= d; k++ {
+					xk := 1.0
+					if k < d {
+						xk = xi[k]
+					}
+					hess.SetSym(j, k, hess.At(j, k)+w*xj*xk)
+				}
+			}
+		}
+		for j := 0; j < d; j++ {
+			grad.SetVec(j, grad.AtVec(j)+l2*theta[j])
+			hess.SetSym(j, j, hess.At(j, j)+l2)
+		}
+		// keeps Hessian positive definite when all probabilities saturate
+		hess.SetSym(d, d, hess.At(d, d)+1e-9)
+
+		var chol mat.Cholesky
+		if !chol.Factorize(hess) {
+			return nil, fmt.Errorf("singular Hessian, samples may be degenerate")
+		}
+		var step mat.VecDense
+		if err := chol.SolveVecTo(&step, grad); err != nil {
+			return nil, err
+		}
+		maxStep := 0.0
+		for j := range theta {
+			theta[j] -= step.AtVec(j)
+			maxStep = math.Max(maxStep, math.Abs(step.AtVec(j)))
+		}
+		if maxStep < logisticTolerance {
+			break
+		}
+	}
+	return &Logistic{Weights: theta[:d], Bias: theta[d]}, nil
+}
+
+// LogOdds returns log-odds of x belonging to the positive class.
+func (l *Logistic) LogOdds(x []float64) float64 {
+	return dot(l.Weights, x) + l.Bias
+}
+
+// Probability returns probability of x belonging to the positive class.
+func (l *Logistic) Probability(x []float64) float64 {
+	return sigmoid(l.LogOdds(x))
+}
+
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
+
+func dot(a, b []float64) float64 {
+	s := 0.0
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
@@ -0,0 +1,208 @@
+package classifier
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	svddIterations = 1000
+	svddTolerance  = 1e-6
+	// alphas within svddEpsilon of their bounds count as bound
+	svddEpsilon = 1e-9
+)
+
+// SVDD is a support vector data description with Gaussian kernel
+// exp(-Gamma·|x-y|²): the smallest ball in kernel feature space that holds
+// all but a fraction of training samples. Only support vectors, samples with
+// positive Alphas, are kept.
+type SVDD struct {
+	Gamma   float64     `json:"gamma"`
+	Support [][]float64 `json:"support"`
+	Alphas  []float64   `json:"alphas"`
+	// Radius2 is the squared radius of the ball.
+	Radius2 float64 `json:"radius2"`
+	// Center2 is the squared norm of the ball center, Σ αi·αj·K(xi, xj).
+	Center2 float64 `json:"center2"`
+}
+
+// TrainSVDD fits SVDD to samples x. Nu in (0, 1] is the upper bound of the
+// fraction of samples left outside the ball. Gamma is set with the median
+// heuristic, 1 over the median squared distance between samples, if it is
+// not positive.
+func TrainSVDD(x [][]float64, nu, gamma float64) (*SVDD, error) {
+	n := len(x)
+	if n < 2 {
+		return nil, fmt.Errorf("at least 2 samples needed, got %d", n)
+	}
+	if !(nu > 0 && nu <= 1) {
+		return nil, fmt.Errorf("nu has to be in (0, 1], got %f", nu)
+	}
+	d := len(x[0])
+	for _, xi := range x {
+		if len(xi) != d {
+			return nil, fmt.Errorf("samples of %d and %d dimensions", d, len(xi))
+		}
+	}
+	if !(gamma > 0) {
+		var dists []float64
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				dists = append(dists, squaredDistance(x[i], x[j]))
+			}
+		}
+		sort.Float64s(dists)
+		m := dists[len(dists)/2]
+		if len(dists)%2 == 0 {
+			m = (dists[len(dists)/2-1] + m) / 2
+		}
+		if m == 0 {
+			return nil, fmt.Errorf("samples do not differ")
+		}
+		gamma = 1 / m
+	}
+
+	k := make([][]float64, n)
+	for i := range k {
+		k[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		k[i][i] = 1
+		for j := i + 1; j < n; j++ {
+			k[i][j] = math.Exp(-gamma * squaredDistance(x[i], x[j]))
+			k[j][i] = k[i][j]
+		}
+	}
+
+	// Dual problem is to minimise αᵀKα with Σα = 1 and 0 <= α <= c, as
+	// K(x, x) = 1. It is solved with SMO, moving weight between the pair of
+	// samples that violates optimality conditions the most.
+	c := math.Min(1, 1/(nu*float64(n)))
+	alphas := make([]float64, n)
+	for i, left := 0, 1.0; left > 0 && i < n; i++ {
+		alphas[i] = math.Min(c, left)
+		left -= alphas[i]
+	}
+	grad := make([]float64, n)
+	for i := range grad {
+		for j := range alphas {
+			grad[i] += 2 * k[i][j] * alphas[j]
+		}
+	}
+	for it := 0; it < svddIterations*n; it++ {
+		up, low := -1, -1
+		for i := range alphas {
+			if alphas[i] > svddEpsilon && (up < 0 || grad[i] > grad[up]) {
+				up = i
+			}
+			if alphas[i] < c-svddEpsilon && (low < 0 || grad[i] < grad[low]) {
+				low = i
+			}
+		}
+		if up < 0 || low < 0 || grad[up]-grad[low] < svddTolerance {
+			break
+		}
+		curvature := k[up][up] + k[low][low] - 2*k[up][low]
+		step := math.Min(alphas[up], c-alphas[low])
+		if curvature > 0 {
+			step = math.Min(step, (grad[up]-grad[low])/(2*curvature))
+		}
+		alphas[up] -= step
+		alphas[low] += step
+		for i := range grad {
+			grad[i] += 2 * step * (k[i][low] - k[i][up])
+		}
+	}
+
+	var center2 float64
+	for i := range alphas {
+		center2 += alphas[i] * grad[i] / 2
+	}
+	// Samples with free alphas lie on the ball. Without any, the radius is
+	// between the farthest sample inside and the closest one outside.
+	var free, inside, outside []float64
+	for i := range alphas {
+		d2 := 1 - grad[i] + center2
+		switch {
+		case alphas[i] <= svddEpsilon:
+			inside = append(inside, d2)
+		case alphas[i] >= c-svddEpsilon:
+			outside = append(outside, d2)
+		default:
+			free = append(free, d2)
+		}
+	}
+	var radius2 float64
+	switch {
+	case len(free) > 0:
+		radius2 = mean(free)
+	case len(inside) > 0 && len(outside) > 0:
+		radius2 = (maximum(inside) + minimum(outside)) / 2
+	case len(inside) > 0:
+		radius2 = maximum(inside)
+	default:
+		radius2 = minimum(outside)
+	}
+	if !(radius2 > 0) {
+		return nil, fmt.Errorf("degenerate ball of squared radius %f", radius2)
+	}
+
+	svdd := &SVDD{Gamma: gamma, Radius2: radius2, Center2: center2}
+	for i := range alphas {
+		if alphas[i] > svddEpsilon {
+			svdd.Support = append(svdd.Support, append([]float64(nil), x[i]...))
+			svdd.Alphas = append(svdd.Alphas, alphas[i])
+		}
+	}
+	return svdd, nil
+}
+
+// Distance2 returns squared distance of x from the ball center in kernel
+// feature space.
+func (s *SVDD) Distance2(x []float64) float64 {
+	d2 := 1 + s.Center2
+	for i, sv := range s.Support {
+		d2 -= 2 * s.Alphas[i] * math.Exp(-s.Gamma*squaredDistance(sv, x))
+	}
+	return d2
+}
+
+// Score returns distance of x from the ball center relative to the radius,
+// so samples with scores below 1 are inside the ball.
+func (s *SVDD) Score(x []float64) float64 {
+	return math.Sqrt(math.Max(s.Distance2(x), 0) / s.Radius2)
+}
+
+func squaredDistance(a, b []float64) float64 {
+	s := 0.0
+	for i := range a {
+		d := a[i] - b[i]
+		s += d * d
+	}
+	return s
+}
+
+func mean(xs []float64) float64 {
+	s := 0.0
+	for _, x := range xs {
+		s += x
+	}
+	return s / float64(len(xs))
+}
+
+func maximum(xs []float64) float64 {
+	m := xs[0]
+	for _, x := range xs[1:] {
+		m = math.Max(m, x)
+	}
+	return m
+}
+
+func minimum(xs []float64) float64 {
+	m := xs[0]
+	for _, x := range xs[1:] {
+		m = math.Min(m, x)
+	}
+	return m
+}
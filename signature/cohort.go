@@ -0,0 +1,97 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/samples"
+	"gonum.org/v1/gonum/stat"
+	"math"
+)
+
+// Score scores sample against the user template like Model.Score, or
+// against the best matching style, see ScoreStyle, and normalises the score
+// with Normalise.
+func (um *UserModel) Score(sample *samples.Sample) (Score, *Model) {
+	score, pattern, _ := um.ScoreStyle(sample)
+	return score, pattern
+}
+
+// Normalise applies Norm and then ZNorm of the user model to score.
+func (um *UserModel) Normalise(score Score) Score {
+	if um.Norm != nil {
+		score = um.Norm.Apply(score)
+	}
+	if um.ZNorm != nil {
+		score = um.ZNorm.Apply(score)
+	}
+	return score
+}
+
+// SetZNorm sets Z-norm of the user model to mean and standard deviation of
+// area scores of impostor cohort samples, usually genuine samples of other
+// users, so normalised scores of different users are comparable.
+func (um *UserModel) SetZNorm(cohort []*samples.Sample) error {
+	um.ZNorm = nil
+	var scores []Score
+	for _, sample := range cohort {
+		score, _ := um.Score(sample)
+		scores = append(scores, score)
+	}
+	norm, err := cohortNorm(scores)
+	if err != nil {
+		return fmt.Errorf("z-norm: %v", err)
+	}
+	um.ZNorm = norm
+	return nil
+}
+
+// TNorm normalises score of sample against the user model, see Score, with
+// mean and standard deviation of scores of the sample against cohort
+// templates of other users, extracting features of the sample once for
+// cohort templates of the same grid. Cohort templates with the same id are
+// skipped. Z-normalised templates give ZT-norm.
+func (um *UserModel) TNorm(score Score, sample *samples.Sample, cohort []*UserModel) (Score, error) {
+	var scores []Score
+	fs := &featureSet{sample: sample}
+	for _, c := range cohort {
+		if c.Id != um.Id && c.Model != nil {
+			s, _, _ := c.scoreStyle(fs.score)
+			scores = append(scores, s)
+		}
+	}
+	norm, err := cohortNorm(scores)
+	if err != nil {
+		return score, fmt.Errorf("t-norm: %v", err)
+	}
+	return norm.Apply(score), nil
+}
+
+// cohortNorm returns ScoreNorm of mean and standard deviation of finite
+// cohort scores of every area.
+func cohortNorm(scores []Score) (*ScoreNorm, error) {
+	values := make(map[AreaType][]float64)
+	for _, score := range scores {
+		for area, s := range score {
+			if !math.IsNaN(s) && !math.IsInf(s, 0) {
+				values[area] = append(values[area], s)
+			}
+		}
+	}
+	norm := &ScoreNorm{
+		Location: make(map[AreaType]float64),
+		Scale:    make(map[AreaType]float64),
+	}
+	for area, vs := range values {
+		if len(vs) < 2 {
+			continue
+		}
+		mean, std := stat.MeanStdDev(vs, nil)
+		if std > 0 {
+			norm.Location[area] = mean
+			norm.Scale[area] = std
+		}
+	}
+	if len(norm.Scale) == 0 {
+		return nil, fmt.Errorf("no area with at least 2 differing cohort scores among %d", len(scores))
+	}
+	return norm, nil
+}
@@ -0,0 +1,245 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/radekwlsk/handauth/signature/features"
+	"io"
+	"os"
+)
+
+const (
+	DefaultAreaFilterFieldThreshold  = 0.03
+	DefaultAreaFilterRowColThreshold = 0.02
+	DefaultStdFilterThreshold        = 0.5
+)
+
+// ModelConfig describes how a Model is built: grid size, areas and features
+// extracted in each of them, filters applied after enrollment and weights of
+// area scores. It is stored in saved templates, so a template is always
+// verified with the configuration it was enrolled with. In JSON it reads:
+//
+//	{
+//	  "rows": 20, "cols": 60,
+//	  "areas": {
+//	    "BasicArea": {"features": ["LengthFeature", "AspectFeature"], "weight": 1.0},
+//	    "GridArea": {"features": ["LengthFeature", "HOGFeature"], "weight": 1.5}
+//	  },
+//	  "area_filter": {"enabled": true, "field_threshold": 0.03, "rowcol_threshold": 0.02},
+//	  "std_filter": {"enabled": true, "threshold": 0.5},
+//	  "scorer": "MahalanobisScorer", "shrinkage": 0.2,
+//	  "estimator": "MedianEstimator",
+//	  "register": true
+//	}
+//
+// Areas missing from the map are not extracted.
+type ModelConfig struct {
+	Rows       uint16                  `json:"rows"`
+	Cols       uint16                  `json:"cols"`
+	Areas      map[AreaType]AreaConfig `json:"areas"`
+	AreaFilter AreaFilterConfig        `json:"area_filter"`
+	StdFilter  StdFilterConfig         `json:"std_filter"`
+	Scorer     Scorer                  `json:"scorer"`
+	// Estimator of feature location and scale used by StdScorer, Trim is the
+	// fraction of observations left out at each end by TrimmedEstimator.
+	Estimator features.Estimator `json:"estimator"`
+	Trim      float64            `json:"trim,omitempty"`
+	// Shrinkage intensity of MahalanobisScorer covariance, estimated from
+	// enrollment samples if 0.
+	Shrinkage float64 `json:"shrinkage,omitempty"`
+	// Register aligns samples to the reference image of the template before
+	// extraction, see samples.Sample.Register.
+	Register bool `json:"register,omitempty"`
+	// Deskew records that samples are deskewed in preprocessing, see
+	// samples.PreprocessConfig. Samples scored against the template have to
+	// be preprocessed the same way, or their SkewFeature is 0 and ink is
+	// shifted between grid cells.
+	Deskew bool `json:"deskew,omitempty"`
+	// Debug logs feature scores. It is not stored in templates.
+	Debug bool `json:"-"`
+}
+
+// AreaConfig lists features extracted in an area and weight of its score,
+// which is 1 if missing from JSON.
+type AreaConfig struct {
+	Features []features.FeatureType `json:"features"`
+	Weight   float64                `json:"weight"`
+}
+
+func (ac *AreaConfig) UnmarshalJSON(b []byte) error {
+	type areaConfig AreaConfig
+	c := areaConfig{Weight: 1.0}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return err
+	}
+	*ac = AreaConfig(c)
+	return nil
+}
+
+type AreaFilterConfig struct {
+	Enabled         bool    `json:"enabled"`
+	FieldThreshold  float64 `json:"field_threshold"`
+	RowColThreshold float64 `json:"rowcol_threshold"`
+}
+
+type StdFilterConfig struct {
+	Enabled   bool    `json:"enabled"`
+	Threshold float64 `json:"threshold"`
+}
+
+// DefaultModelConfig returns configuration of given grid size with all areas,
+// each with every registered feature that applies to it and is not optional,
+// and both filters with default thresholds.
+func DefaultModelConfig(rows, cols uint16) ModelConfig {
+	config := ModelConfig{
+		Rows:  rows,
+		Cols:  cols,
+		Areas: make(map[AreaType]AreaConfig),
+		AreaFilter: AreaFilterConfig{
+			Enabled:         true,
+			FieldThreshold:  DefaultAreaFilterFieldThreshold,
+			RowColThreshold: DefaultAreaFilterRowColThreshold,
+		},
+		StdFilter: StdFilterConfig{
+			Enabled:   true,
+			Threshold: DefaultStdFilterThreshold,
+		},
+	}
+	for area := BasicAreaType; area <= GridAreaType; area++ {
+		areaConfig := AreaConfig{Weight: 1.0}
+		for _, ftrType := range features.Registered() {
+			if ftrType.Areas()&area.Mask() != 0 && !ftrType.Optional() {
+				areaConfig.Features = append(areaConfig.Features, ftrType)
+			}
+		}
+		config.Areas[area] = areaConfig
+	}
+	return config
+}
+
+func LoadModelConfig(r io.Reader) (ModelConfig, error) {
+	var config ModelConfig
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+func ReadModelConfig(filename string) (ModelConfig, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return ModelConfig{}, err
+	}
+	defer f.Close()
+	config, err := LoadModelConfig(f)
+	if err != nil {
+		return config, fmt.Errorf("%s: %v", filename, err)
+	}
+	return config, nil
+}
+
+// MarshalBinary encodes config as JSON, which unlike gob writes Areas map in
+// a stable order.
+func (config ModelConfig) MarshalBinary() ([]byte, error) {
+	return json.Marshal(config)
+}
+
+func (config *ModelConfig) UnmarshalBinary(b []byte) error {
+	return json.Unmarshal(b, config)
+}
+
+// equal reports whether config and other are stored alike in templates, so
+// features extracted with one are comparable with the other. Debug is not
+// compared and feature lists are compared in order.
+func (config ModelConfig) equal(other ModelConfig) bool {
+	a, err := config.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	b, err := other.MarshalBinary()
+	return err == nil && bytes.Equal(a, b)
+}
+
+func (config ModelConfig) Validate() error {
+	if len(config.Areas) == 0 {
+		return fmt.Errorf("no areas configured")
+	}
+	if config.hasGrid() && (config.Rows < 1 || config.Cols < 1) {
+		return fmt.Errorf("grid has to have at least 1 row and column, got %dx%d", config.Rows, config.Cols)
+	}
+	for area, areaConfig := range config.Areas {
+		if len(areaConfig.Features) == 0 {
+			return fmt.Errorf("no features configured for %s", area)
+		}
+		seen := make(map[features.FeatureType]bool)
+		for _, ftrType := range areaConfig.Features {
+			if seen[ftrType] {
+				return fmt.Errorf("%s configured twice for %s", ftrType, area)
+			}
+			seen[ftrType] = true
+		}
+		if !(areaConfig.Weight > 0) {
+			return fmt.Errorf("weight of %s has to be positive, got %f", area, areaConfig.Weight)
+		}
+	}
+	if config.AreaFilter.Enabled && !(config.AreaFilter.FieldThreshold > 0 && config.AreaFilter.RowColThreshold > 0) {
+		return fmt.Errorf("area filter thresholds have to be positive, got %f and %f",
+			config.AreaFilter.FieldThreshold, config.AreaFilter.RowColThreshold)
+	}
+	if config.StdFilter.Enabled && !(config.StdFilter.Threshold > 0) {
+		return fmt.Errorf("std filter threshold has to be positive, got %f", config.StdFilter.Threshold)
+	}
+	if config.Scorer < StdScorer || config.Scorer > MahalanobisScorer {
+		return fmt.Errorf("unknown scorer %d", int(config.Scorer))
+	}
+	if config.Estimator < features.MeanEstimator || config.Estimator > features.TrimmedEstimator {
+		return fmt.Errorf("unknown estimator %d", int(config.Estimator))
+	}
+	if config.Trim < 0 || config.Trim >= 0.5 {
+		return fmt.Errorf("trim has to be in [0, 0.5), got %f", config.Trim)
+	}
+	if config.Shrinkage < 0 || config.Shrinkage > 1 {
+		return fmt.Errorf("shrinkage has to be in [0, 1], got %f", config.Shrinkage)
+	}
+	return nil
+}
+
+func (config ModelConfig) Has(area AreaType) bool {
+	_, ok := config.Areas[area]
+	return ok
+}
+
+func (config ModelConfig) hasGrid() bool {
+	return config.Has(GridAreaType) || config.Has(RowAreaType) || config.Has(ColAreaType)
+}
+
+func (config ModelConfig) HasFeature(area AreaType, ftrType features.FeatureType) bool {
+	for _, t := range config.Areas[area].Features {
+		if t == ftrType {
+			return true
+		}
+	}
+	return false
+}
+
+func (config ModelConfig) Weights() AreaThresholdWeights {
+	weights := make(AreaThresholdWeights)
+	for area, areaConfig := range config.Areas {
+		weights[area] = areaConfig.Weight
+	}
+	return weights
+}
+
+func (config ModelConfig) featureMap(area AreaType) features.FeatureMap {
+	m := make(features.FeatureMap)
+	for _, ftrType := range config.Areas[area].Features {
+		ftr, err := features.New(ftrType)
+		if err != nil {
+			panic(err)
+		}
+		ftr.SetEstimator(config.Estimator, config.Trim)
+		m[ftrType] = ftr
+	}
+	return m
+}
@@ -0,0 +1,79 @@
+package signature
+
+import (
+	"fmt"
+	"math"
+)
+
+// Decision is the outcome of verification of a sample.
+type Decision int
+
+const (
+	Reject Decision = iota
+	Accept
+	// Review sends the sample to manual review.
+	Review
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Reject:
+		return "Reject"
+	case Accept:
+		return "Accept"
+	case Review:
+		return "Review"
+	default:
+		return fmt.Sprintf("Decision(%d)", int(d))
+	}
+}
+
+func (d Decision) MarshalText() ([]byte, error) {
+	if d < Reject || d > Review {
+		return nil, fmt.Errorf("unknown decision %d", int(d))
+	}
+	return []byte(d.String()), nil
+}
+
+func (d *Decision) UnmarshalText(text []byte) error {
+	for t := Reject; t <= Review; t++ {
+		if t.String() == string(text) {
+			*d = t
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown decision %q", string(text))
+}
+
+// Decide accepts value below accept threshold, rejects value at or above
+// reject threshold and sends value in between to review. NaN values are
+// rejected.
+func Decide(value, accept, reject float64) Decision {
+	switch {
+	case value < accept:
+		return Accept
+	case value < reject:
+		return Review
+	default:
+		return Reject
+	}
+}
+
+// Decide is the three-way Check: the sample is rejected if any weighted area
+// score reaches reject threshold, accepted if all are below accept threshold
+// and sent to review otherwise. Areas with NaN scores are skipped.
+func (s Score) Decide(accept, reject float64, weights AreaThresholdWeights) Decision {
+	decision := Accept
+	for area, score := range s {
+		if math.IsNaN(score) {
+			continue
+		}
+		switch Decide(score*weight(weights, area), accept, reject) {
+		case Reject:
+			return Reject
+		case Review:
+			decision = Review
+		}
+	}
+	return decision
+}
@@ -0,0 +1,150 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature/classifier"
+	"github.com/radekwlsk/handauth/signature/features"
+	"io"
+	"math"
+	"sort"
+)
+
+// Dichotomy is a writer-independent classifier of dissimilarity vectors of
+// sample pairs, trained once on pairs of a development dataset and reused
+// for every user. Dissimilarity vector holds the mean absolute difference of
+// feature values over cells of an area, one component per area and feature
+// type of Config, so it does not depend on which cells have ink. Samples are
+// extracted with Config, but neither filtered nor registered, as there is no
+// template to register them to. In JSON it reads:
+//
+//	{"config": {...}, "features": [{"area": "BasicArea", "feature": "LengthFeature"}, ...],
+//	 "weights": [0.02, ...], "bias": -1.3}
+type Dichotomy struct {
+	Config   ModelConfig        `json:"config"`
+	Features []DichotomyFeature `json:"features"`
+	classifier.Logistic
+}
+
+type DichotomyFeature struct {
+	Area AreaType             `json:"area"`
+	Type features.FeatureType `json:"feature"`
+}
+
+// NewDichotomy creates untrained Dichotomy with all areas and features of
+// config.
+func NewDichotomy(config ModelConfig) (*Dichotomy, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	d := &Dichotomy{Config: config}
+	for area, areaConfig := range config.Areas {
+		for _, ftrType := range areaConfig.Features {
+			d.Features = append(d.Features, DichotomyFeature{Area: area, Type: ftrType})
+		}
+	}
+	sort.Slice(d.Features, func(i, j int) bool {
+		if d.Features[i].Area != d.Features[j].Area {
+			return d.Features[i].Area < d.Features[j].Area
+		}
+		return d.Features[i].Type < d.Features[j].Type
+	})
+	return d, nil
+}
+
+// Extract returns model of a single sample to compare with Dissimilarity.
+func (d *Dichotomy) Extract(sample *samples.Sample) *Model {
+	config := d.Config
+	config.Register = false
+	model := newModel(config, nil)
+	model.extractPattern(sample)
+	return model
+}
+
+// Dissimilarity returns dissimilarity vector of samples extracted with
+// Extract. Components of areas neither sample has cells in are 0.
+func (d *Dichotomy) Dissimilarity(a, b *Model) []float64 {
+	sums := make(map[DichotomyFeature]float64)
+	counts := make(map[DichotomyFeature]int)
+	for area := range d.Config.Areas {
+		for _, af := range a.areaFeatures(area) {
+			if other := b.feature(area, af); other != nil {
+				f := DichotomyFeature{Area: area, Type: af.ftrType}
+				sums[f] += math.Abs(af.ftr.Value() - other.Value())
+				counts[f]++
+			}
+		}
+	}
+	v := make([]float64, len(d.Features))
+	for i, f := range d.Features {
+		if counts[f] > 0 {
+			v[i] = sums[f] / float64(counts[f])
+		}
+	}
+	return v
+}
+
+// Train fits the classifier to dissimilarity vectors of pairs of samples of
+// the same writer and of different writers, with l2 penalty of weights, see
+// classifier.TrainLogistic.
+func (d *Dichotomy) Train(same, different [][]float64, l2 float64) error {
+	x := append(append([][]float64(nil), same...), different...)
+	y := make([]bool, len(x))
+	for i := len(same); i < len(y); i++ {
+		y[i] = true
+	}
+	logistic, err := classifier.TrainLogistic(x, y, l2)
+	if err != nil {
+		return err
+	}
+	d.Logistic = *logistic
+	return nil
+}
+
+// Score returns mean log-odds of questioned sample being written by
+// a different writer than each of reference samples, so like area scores
+// lower scores mean samples more similar to references, with thresholds
+// usually around 0.
+func (d *Dichotomy) Score(questioned *Model, references []*Model) (float64, error) {
+	if len(references) == 0 {
+		return 0, fmt.Errorf("no reference samples")
+	}
+	if len(d.Weights) != len(d.Features) {
+		return 0, fmt.Errorf("dichotomy of %d features has %d weights", len(d.Features), len(d.Weights))
+	}
+	var sum float64
+	for _, reference := range references {
+		sum += d.LogOdds(d.Dissimilarity(questioned, reference))
+	}
+	return sum / float64(len(references)), nil
+}
+
+func (d *Dichotomy) validate() error {
+	if err := d.Config.Validate(); err != nil {
+		return err
+	}
+	if len(d.Weights) != len(d.Features) {
+		return fmt.Errorf("dichotomy of %d features has %d weights", len(d.Features), len(d.Weights))
+	}
+	return nil
+}
+
+func ReadDichotomy(filename string) (*Dichotomy, error) {
+	d := new(Dichotomy)
+	if err := readJSON(filename, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func LoadDichotomy(r io.Reader) (*Dichotomy, error) {
+	d := new(Dichotomy)
+	if err := loadJSON(r, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Dichotomy) Write(filename string) error {
+	return writeJSON(filename, d)
+}
@@ -0,0 +1,285 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature/features"
+	"sort"
+	"time"
+)
+
+// FormatVersion is the version of the template format written by Model's
+// MarshalJSON and MarshalBinary. Readers accept templates of this and older
+// versions and reject newer ones.
+//
+// Version history:
+//
+//	1: initial format
+//	2: samples count
+//	3: time-decayed statistics
+//	4: vector features
+//	5: model configuration
+//	6: feature observations
+//	7: variance prior
+//	8: reference image
+const FormatVersion = 8
+
+// modelData is the serialised form of a Model. In JSON it reads:
+//
+//	{
+//	  "version": 8,
+//	  "rows": 20, "cols": 60, "samples": 10, "half_life": 0,
+//	  "config": {"rows": 20, "cols": 60, "areas": {...}, ...},
+//	  "field_area": 91.2, "row_area": 625.0, "col_area": 208.3,
+//	  "areas": ["BasicArea", "RowArea", "ColArea", "GridArea"],
+//	  "basic": [{"type": "LengthFeature", "mean": 1520.4, "variance": 2211.9,
+//	             "std": 47.03, "min": 1450, "max": 1601,
+//	             "observations": [1502, 1450, ...]}, ...],
+//	  "grid": [{"row": 0, "col": 3, "features": [...]}, ...],
+//	  "row":  [{"row": 0, "col": -1, "features": [...]}, ...],
+//	  "col":  [{"row": -1, "col": 3, "features": [...]}, ...],
+//	  "reference": {"width": 500, "height": 160, "cols": 100, "rows": 32,
+//	                "pixels": [0, 0.1, ...]}
+//	}
+//
+// Areas lists the areas the model was built with, so an area whose every
+// cell was removed by AreaFilter is told apart from a disabled one. Cells
+// are addressed like in samples.SampleGrid.At, with -1 for the unused index,
+// and only cells and features that survived AreaFilter and StdFilter are
+// present. Samples is the number of samples the statistics were gathered
+// from, 0 in templates of version 1. Half-life is in nanoseconds, 0 unless
+// the model was built with ExtractAt. Config is the ModelConfig the model was
+// enrolled with; templates older than version 5 get one derived from the
+// stored areas and features, with unit weights and filters disabled.
+// Reference is the mean image samples are registered to, present only if
+// the config registers samples. The binary form is the gob encoding of the
+// same structure.
+type modelData struct {
+	Version   int                `json:"version"`
+	Rows      uint16             `json:"rows"`
+	Cols      uint16             `json:"cols"`
+	Samples   int                `json:"samples"`
+	HalfLife  time.Duration      `json:"half_life"`
+	Config    *ModelConfig       `json:"config,omitempty"`
+	FieldArea float64            `json:"field_area"`
+	RowArea   float64            `json:"row_area"`
+	ColArea   float64            `json:"col_area"`
+	Areas     []AreaType         `json:"areas"`
+	Basic     []features.State   `json:"basic"`
+	Grid      []cellData         `json:"grid"`
+	Row       []cellData         `json:"row"`
+	Col       []cellData         `json:"col"`
+	Reference *samples.Reference `json:"reference,omitempty"`
+}
+
+type cellData struct {
+	Row      int              `json:"row"`
+	Col      int              `json:"col"`
+	Features []features.State `json:"features"`
+}
+
+func (t AreaType) MarshalText() ([]byte, error) {
+	if t < BasicAreaType || t > GridAreaType {
+		return nil, fmt.Errorf("unknown area type %d", int(t))
+	}
+	return []byte(t.String()), nil
+}
+
+func (t *AreaType) UnmarshalText(text []byte) error {
+	for a := BasicAreaType; a <= GridAreaType; a++ {
+		if a.String() == string(text) {
+			*t = a
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown area type %q", string(text))
+}
+
+func (model *Model) data() *modelData {
+	d := &modelData{
+		Version:   FormatVersion,
+		Rows:      model.config.Rows,
+		Cols:      model.config.Cols,
+		Samples:   model.samples,
+		HalfLife:  model.halfLife,
+		Config:    &model.config,
+		FieldArea: model.fieldArea,
+		RowArea:   model.rowArea,
+		ColArea:   model.colArea,
+		Areas:     []AreaType{},
+		Reference: model.reference,
+	}
+	if model.basic != nil {
+		d.Areas = append(d.Areas, BasicAreaType)
+		d.Basic = model.basic.States()
+	}
+	if model.row != nil {
+		d.Areas = append(d.Areas, RowAreaType)
+		d.Row = make([]cellData, 0, len(model.row))
+		for r, ftrMap := range model.row {
+			d.Row = append(d.Row, cellData{Row: r, Col: -1, Features: ftrMap.States()})
+		}
+		sortCells(d.Row)
+	}
+	if model.col != nil {
+		d.Areas = append(d.Areas, ColAreaType)
+		d.Col = make([]cellData, 0, len(model.col))
+		for c, ftrMap := range model.col {
+			d.Col = append(d.Col, cellData{Row: -1, Col: c, Features: ftrMap.States()})
+		}
+		sortCells(d.Col)
+	}
+	if model.grid != nil {
+		d.Areas = append(d.Areas, GridAreaType)
+		d.Grid = make([]cellData, 0, len(model.grid))
+		for rc, ftrMap := range model.grid {
+			d.Grid = append(d.Grid, cellData{Row: rc[0], Col: rc[1], Features: ftrMap.States()})
+		}
+		sortCells(d.Grid)
+	}
+	return d
+}
+
+func sortCells(cells []cellData) {
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Row != cells[j].Row {
+			return cells[i].Row < cells[j].Row
+		}
+		return cells[i].Col < cells[j].Col
+	})
+}
+
+func (model *Model) load(d *modelData) error {
+	if d.Version < 1 || d.Version > FormatVersion {
+		return fmt.Errorf("unsupported template format version %d", d.Version)
+	}
+	m := &Model{
+		samples:   d.Samples,
+		halfLife:  d.HalfLife,
+		fieldArea: d.FieldArea,
+		rowArea:   d.RowArea,
+		colArea:   d.ColArea,
+		reference: d.Reference,
+	}
+	for _, area := range d.Areas {
+		var err error
+		switch area {
+		case BasicAreaType:
+			if m.basic, err = features.RestoreMap(d.Basic); err != nil {
+				return fmt.Errorf("basic: %v", err)
+			}
+		case GridAreaType:
+			m.grid = make(GridFeatureMap, len(d.Grid))
+			for _, cell := range d.Grid {
+				if m.grid[[2]int{cell.Row, cell.Col}], err = features.RestoreMap(cell.Features); err != nil {
+					return fmt.Errorf("grid (%d,%d): %v", cell.Row, cell.Col, err)
+				}
+			}
+		case RowAreaType:
+			m.row = make(RowFeatureMap, len(d.Row))
+			for _, cell := range d.Row {
+				if m.row[cell.Row], err = features.RestoreMap(cell.Features); err != nil {
+					return fmt.Errorf("row %d: %v", cell.Row, err)
+				}
+			}
+		case ColAreaType:
+			m.col = make(ColFeatureMap, len(d.Col))
+			for _, cell := range d.Col {
+				if m.col[cell.Col], err = features.RestoreMap(cell.Features); err != nil {
+					return fmt.Errorf("col %d: %v", cell.Col, err)
+				}
+			}
+		}
+	}
+	if d.Config != nil {
+		m.config = *d.Config
+	} else {
+		m.config = m.derivedConfig(d.Rows, d.Cols)
+	}
+	if m.reference != nil {
+		if err := m.reference.Validate(); err != nil {
+			return err
+		}
+	}
+	m.forEachFeature(func(ftr *features.Feature) {
+		ftr.SetEstimator(m.config.Estimator, m.config.Trim)
+	})
+	*model = *m
+	return nil
+}
+
+// derivedConfig returns configuration of a model loaded from a template that
+// does not store one.
+func (model *Model) derivedConfig(rows, cols uint16) ModelConfig {
+	config := ModelConfig{
+		Rows:  rows,
+		Cols:  cols,
+		Areas: make(map[AreaType]AreaConfig),
+	}
+	add := func(area AreaType, ftrMap features.FeatureMap) {
+		areaConfig := config.Areas[area]
+		areaConfig.Weight = 1.0
+		for ftrType := range ftrMap {
+			if !config.HasFeature(area, ftrType) {
+				areaConfig.Features = append(areaConfig.Features, ftrType)
+			}
+		}
+		sort.Slice(areaConfig.Features, func(i, j int) bool {
+			return areaConfig.Features[i] < areaConfig.Features[j]
+		})
+		config.Areas[area] = areaConfig
+	}
+	if model.basic != nil {
+		add(BasicAreaType, model.basic)
+	}
+	if model.grid != nil {
+		add(GridAreaType, nil)
+		for _, ftrMap := range model.grid {
+			add(GridAreaType, ftrMap)
+		}
+	}
+	if model.row != nil {
+		add(RowAreaType, nil)
+		for _, ftrMap := range model.row {
+			add(RowAreaType, ftrMap)
+		}
+	}
+	if model.col != nil {
+		add(ColAreaType, nil)
+		for _, ftrMap := range model.col {
+			add(ColAreaType, ftrMap)
+		}
+	}
+	return config
+}
+
+func (model *Model) MarshalJSON() ([]byte, error) {
+	return json.Marshal(model.data())
+}
+
+func (model *Model) UnmarshalJSON(b []byte) error {
+	d := new(modelData)
+	if err := json.Unmarshal(b, d); err != nil {
+		return err
+	}
+	return model.load(d)
+}
+
+func (model *Model) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(model.data()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (model *Model) UnmarshalBinary(b []byte) error {
+	d := new(modelData)
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(d); err != nil {
+		return err
+	}
+	return model.load(d)
+}
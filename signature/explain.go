@@ -0,0 +1,178 @@
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature/features"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+// Explanation tells which features of a sample deviated from the template
+// and by how much, see Model.Explain.
+type Explanation struct {
+	Areas []AreaExplanation `json:"areas"`
+}
+
+type AreaExplanation struct {
+	Area  AreaType `json:"area"`
+	Score float64  `json:"score"`
+	// Features sorted by contribution, the highest first.
+	Features []FeatureExplanation `json:"features"`
+}
+
+// FeatureExplanation is a single feature of a cell of an area. Cells are
+// addressed like in samples.SampleGrid.At, with -1 for the unused index.
+// Mean and Std are location and scale of the template feature used to score
+// the sample, mean and standard deviation with features.MeanEstimator.
+// Contributions of area features sum up to the area score; with
+// MahalanobisScorer they are shares of absolute z-scores, which ignore
+// feature correlations. Features with infinite z-scores, values differing
+// from template features of zero scale, make the area score infinite and
+// take all of it, their contributions are infinite and others 0. Features
+// with NaN z-scores are listed last. Non-finite values are null in JSON.
+type FeatureExplanation struct {
+	Row          int                  `json:"row"`
+	Col          int                  `json:"col"`
+	Type         features.FeatureType `json:"feature"`
+	Mean         float64              `json:"mean"`
+	Std          float64              `json:"std"`
+	Value        float64              `json:"value"`
+	ZScore       float64              `json:"z"`
+	Contribution float64              `json:"contribution"`
+}
+
+func (f FeatureExplanation) MarshalJSON() ([]byte, error) {
+	finite := func(x float64) *float64 {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil
+		}
+		return &x
+	}
+	return json.Marshal(struct {
+		Row          int                  `json:"row"`
+		Col          int                  `json:"col"`
+		Type         features.FeatureType `json:"feature"`
+		Mean         *float64             `json:"mean"`
+		Std          *float64             `json:"std"`
+		Value        *float64             `json:"value"`
+		ZScore       *float64             `json:"z"`
+		Contribution *float64             `json:"contribution"`
+	}{f.Row, f.Col, f.Type, finite(f.Mean), finite(f.Std), finite(f.Value), finite(f.ZScore),
+		finite(f.Contribution)})
+}
+
+func (a AreaExplanation) MarshalJSON() ([]byte, error) {
+	var score *float64
+	if !math.IsNaN(a.Score) && !math.IsInf(a.Score, 0) {
+		score = &a.Score
+	}
+	return json.Marshal(struct {
+		Area     AreaType             `json:"area"`
+		Score    *float64             `json:"score"`
+		Features []FeatureExplanation `json:"features"`
+	}{a.Area, score, a.Features})
+}
+
+// Explain scores sample against the model like Score and explains the score.
+func (model *Model) Explain(sample *samples.Sample) (Score, *Explanation) {
+	score, pattern := model.Score(sample)
+	return score, model.explain(pattern, score)
+}
+
+// Explain scores sample against the user template or its best matching
+// style like ScoreStyle and explains the normalised score against the
+// template it was scored with.
+func (um *UserModel) Explain(sample *samples.Sample) (Score, *Explanation, int) {
+	score, pattern, style := um.ScoreStyle(sample)
+	model := um.Model
+	if style >= 0 {
+		model = um.Styles[style]
+	}
+	return score, model.explain(pattern, score), style
+}
+
+func (model *Model) explain(pattern *Model, score Score) *Explanation {
+	var areas []AreaType
+	for area := range score {
+		areas = append(areas, area)
+	}
+	sort.Slice(areas, func(i, j int) bool { return areas[i] < areas[j] })
+
+	e := new(Explanation)
+	for _, area := range areas {
+		ae := AreaExplanation{Area: area, Score: score[area]}
+		var total float64
+		var infinite int
+		for _, af := range model.areaFeatures(area) {
+			other := pattern.feature(area, af)
+			if other == nil {
+				continue
+			}
+			z := af.ftr.Score(other)
+			switch {
+			case math.IsInf(z, 0):
+				infinite++
+			case !math.IsNaN(z):
+				total += math.Abs(z)
+			}
+			ae.Features = append(ae.Features, FeatureExplanation{
+				Row:    af.row,
+				Col:    af.col,
+				Type:   af.ftrType,
+				Mean:   af.ftr.Location(),
+				Std:    af.ftr.Scale(),
+				Value:  other.Value(),
+				ZScore: z,
+			})
+		}
+		for i := range ae.Features {
+			z := ae.Features[i].ZScore
+			switch {
+			case infinite > 0 && math.IsInf(z, 0):
+				ae.Features[i].Contribution = math.Inf(1)
+			case infinite > 0:
+				ae.Features[i].Contribution = 0
+			case total > 0:
+				ae.Features[i].Contribution = ae.Score * math.Abs(z) / total
+			}
+		}
+		sort.SliceStable(ae.Features, func(i, j int) bool {
+			a, b := ae.Features[i].Contribution, ae.Features[j].Contribution
+			if math.IsNaN(b) {
+				return !math.IsNaN(a)
+			}
+			return a > b
+		})
+		e.Areas = append(e.Areas, ae)
+	}
+	return e
+}
+
+// Render writes explanation as a table of top features of every area with
+// the highest contributions, all if top is 0.
+func (e *Explanation) Render(w io.Writer, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	for _, ae := range e.Areas {
+		if _, err := fmt.Fprintf(tw, "%s score %.3f\n", ae.Area, ae.Score); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(tw, "\trow\tcol\tfeature\tmean\tstd\tvalue\tz\tcontribution\t"); err != nil {
+			return err
+		}
+		for i, f := range ae.Features {
+			if top > 0 && i == top {
+				break
+			}
+			_, err := fmt.Fprintf(tw, "\t%d\t%d\t%s\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t\n",
+				f.Row, f.Col, f.Type, f.Mean, f.Std, f.Value, f.ZScore, f.Contribution)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,133 @@
+package features
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+func (t FeatureType) MarshalText() ([]byte, error) {
+	r, ok := registered(t)
+	if !ok {
+		return nil, fmt.Errorf("unknown feature type %d", int(t))
+	}
+	return []byte(r.name), nil
+}
+
+func (t *FeatureType) UnmarshalText(text []byte) error {
+	parsed, err := ParseFeatureType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// State is the serialisable part of a Feature: its type and the statistics
+// gathered by Update. The feature function itself is restored from Type.
+// Time-decayed features also keep their half-life, effective weight and
+// time of the newest observation in Unix nanoseconds. Vector features keep
+// the metric and per dimension means and variances. Observations are the
+// values of samples the feature was updated with, and PriorVariance and
+// PriorStrength the population prior set with SetPrior.
+type State struct {
+	Type      FeatureType   `json:"type"`
+	Mean      float64       `json:"mean"`
+	Variance  float64       `json:"variance"`
+	Std       float64       `json:"std"`
+	Min       float64       `json:"min"`
+	Max       float64       `json:"max"`
+	HalfLife  time.Duration `json:"half_life,omitempty"`
+	Weight    float64       `json:"weight,omitempty"`
+	Updated   int64         `json:"updated,omitempty"`
+	Metric    Metric        `json:"metric,omitempty"`
+	Means     []float64     `json:"means,omitempty"`
+	Variances []float64     `json:"variances,omitempty"`
+
+	Observations  []float64 `json:"observations,omitempty"`
+	PriorVariance float64   `json:"prior_variance,omitempty"`
+	PriorStrength float64   `json:"prior_strength,omitempty"`
+}
+
+func (f *Feature) State() State {
+	state := State{
+		Type:      f.fType,
+		Mean:      f.mean,
+		Variance:  f.variance,
+		Std:       f.std,
+		Min:       f.min,
+		Max:       f.max,
+		HalfLife:  f.halfLife,
+		Weight:    f.weight,
+		Metric:    f.metric,
+		Means:     f.means,
+		Variances: f.variances,
+
+		Observations:  f.observations,
+		PriorVariance: f.priorVariance,
+		PriorStrength: f.priorStrength,
+	}
+	if !f.updated.IsZero() {
+		state.Updated = f.updated.UnixNano()
+	}
+	return state
+}
+
+func Restore(state State) (*Feature, error) {
+	f, err := New(state.Type)
+	if err != nil {
+		return nil, err
+	}
+	f.mean = state.Mean
+	f.variance = state.Variance
+	f.std = state.Std
+	f.min = state.Min
+	f.max = state.Max
+	f.halfLife = state.HalfLife
+	f.weight = state.Weight
+	if f.vector != nil {
+		if len(state.Means) != len(state.Variances) {
+			return nil, fmt.Errorf("%s has %d means and %d variances",
+				state.Type, len(state.Means), len(state.Variances))
+		}
+		f.metric = state.Metric
+		f.means = state.Means
+		f.variances = state.Variances
+	}
+	if state.Updated != 0 {
+		f.updated = time.Unix(0, state.Updated)
+	}
+	f.observations = state.Observations
+	f.priorVariance = state.PriorVariance
+	f.priorStrength = state.PriorStrength
+	f.estimate()
+	return f, nil
+}
+
+func (m FeatureMap) States() []State {
+	types := make([]FeatureType, 0, len(m))
+	for t := range m {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	states := make([]State, len(types))
+	for i, t := range types {
+		states[i] = m[t].State()
+	}
+	return states
+}
+
+func RestoreMap(states []State) (FeatureMap, error) {
+	m := make(FeatureMap, len(states))
+	for _, state := range states {
+		if _, ok := m[state.Type]; ok {
+			return nil, fmt.Errorf("duplicate feature %s", state.Type)
+		}
+		ftr, err := Restore(state)
+		if err != nil {
+			return nil, err
+		}
+		m[state.Type] = ftr
+	}
+	return m, nil
+}
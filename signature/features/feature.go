@@ -6,30 +6,16 @@ import (
 	"gonum.org/v1/gonum/stat"
 	"math"
 	"strings"
+	"time"
 )
 
-var FeatureFlags = map[FeatureType]bool{
-	LengthFeatureType:      true,
-	GradientFeatureType:    true,
-	AspectFeatureType:      true,
-	HOGFeatureType:         true,
-	CornersFeatureType:     false,
-	MassCenterXFeatureType: true,
-	MassCenterYFeatureType: true,
-}
-
 type FeatureType int
 
 func (t FeatureType) String() string {
-	return []string{
-		"LengthFeature",
-		"GradientFeature",
-		"AspectFeature",
-		"HOGFeature",
-		"CornersFeature",
-		"MassCenterXFeature",
-		"MassCenterYFeature",
-	}[t]
+	if r, ok := registered(t); ok {
+		return r.name
+	}
+	return fmt.Sprintf("FeatureType(%d)", int(t))
 }
 
 const (
@@ -40,6 +26,8 @@ const (
 	CornersFeatureType
 	MassCenterXFeatureType
 	MassCenterYFeatureType
+	HOGHistogramFeatureType
+	SkewFeatureType
 )
 
 type Feature struct {
@@ -50,6 +38,27 @@ type Feature struct {
 	max      float64
 	min      float64
 	function func(sample *samples.Sample) float64
+	halfLife time.Duration
+	weight   float64
+	updated  time.Time
+	// vector features compute a vector of values per sample, keep per
+	// dimension statistics and use summary of the vector as scalar value
+	vector    func(sample *samples.Sample) []float64
+	summary   func(vector []float64) float64
+	metric    Metric
+	means     []float64
+	variances []float64
+	// values of at most nSamples most recent samples passed to Update, used
+	// by scorers that need more than mean and variance
+	observations []float64
+	// estimates used by Score, see SetEstimator
+	estimator Estimator
+	trim      float64
+	location  float64
+	scale     float64
+	// population prior of variance, see SetPrior
+	priorVariance float64
+	priorStrength float64
 }
 
 func (f *Feature) String() string {
@@ -64,8 +73,22 @@ func (f *Feature) String() string {
 	return sb.String()
 }
 
+func (f *Feature) sampleValue(sample *samples.Sample) (float64, []float64) {
+	if f.vector != nil {
+		vector := f.vector(sample)
+		return f.summary(vector), vector
+	}
+	return f.function(sample), nil
+}
+
 func (f *Feature) Update(sample *samples.Sample, nSamples int) {
-	value := f.function(sample)
+	value, vector := f.sampleValue(sample)
+	if vector != nil && nSamples > 0 {
+		f.updateVector(vector, nSamples)
+	}
+	if nSamples > 0 {
+		f.observe(value, nSamples)
+	}
 
 	switch nSamples {
 	case 0:
@@ -93,6 +116,146 @@ func (f *Feature) Update(sample *samples.Sample, nSamples int) {
 			f.min = value
 		}
 	}
+	f.estimate()
+}
+
+func (f *Feature) observe(value float64, nSamples int) {
+	f.observations = append(f.observations, value)
+	if len(f.observations) > nSamples {
+		f.observations = append([]float64(nil), f.observations[len(f.observations)-nSamples:]...)
+	}
+}
+
+// Observations returns values of samples the feature was updated with, oldest
+// first. Features updated with UpdateAt keep no observations.
+func (f *Feature) Observations() []float64 {
+	return f.observations
+}
+
+// Merge combines statistics of f gathered from nSelf samples with statistics
+// of other gathered from nOther samples, as if all of them were passed to
+// Update of a single Feature.
+func (f *Feature) Merge(other *Feature, nSelf, nOther int) error {
+	if err := f.CheckMerge(other, nSelf, nOther); err != nil {
+		return err
+	}
+	n := float64(nSelf + nOther)
+	w1 := float64(nSelf) / n
+	w2 := float64(nOther) / n
+	delta := other.mean - f.mean
+
+	f.mean = f.mean + delta*w2
+	f.variance = w1*f.variance + w2*other.variance + delta*delta*w1*w2
+	f.std = math.Sqrt(f.variance)
+
+	if f.means != nil {
+		for i := range f.means {
+			delta := other.means[i] - f.means[i]
+			f.means[i] += delta * w2
+			f.variances[i] = w1*f.variances[i] + w2*other.variances[i] + delta*delta*w1*w2
+		}
+	}
+
+	if other.max > f.max {
+		f.max = other.max
+	}
+	if other.min < f.min {
+		f.min = other.min
+	}
+	f.observations = append(append([]float64(nil), f.observations...), other.observations...)
+	f.estimate()
+	return nil
+}
+
+// CheckMerge returns the error Merge would fail with, without changing f.
+func (f *Feature) CheckMerge(other *Feature, nSelf, nOther int) error {
+	if f.fType != other.fType {
+		return fmt.Errorf("cannot merge %s with %s", f.fType, other.fType)
+	}
+	if nSelf < 1 || nOther < 1 {
+		return fmt.Errorf("both features have to be updated with at least 1 sample")
+	}
+	if len(f.means) != len(other.means) {
+		return fmt.Errorf("cannot merge %s of %d and %d dimensions", f.fType, len(f.means), len(other.means))
+	}
+	return nil
+}
+
+// SetHalfLife turns on exponential forgetting used by UpdateAt: weight of an
+// observation halves every halfLife between its timestamp and the newest one.
+func (f *Feature) SetHalfLife(halfLife time.Duration) {
+	f.halfLife = halfLife
+}
+
+func (f *Feature) HalfLife() time.Duration {
+	return f.halfLife
+}
+
+// Weight is the effective number of samples gathered by UpdateAt.
+func (f *Feature) Weight() float64 {
+	return f.weight
+}
+
+func (f *Feature) Updated() time.Time {
+	return f.updated
+}
+
+// UpdateAt updates weighted mean and variance with the value of sample taken
+// at given time. Older observations decay according to half-life set with
+// SetHalfLife, which has to be done before the first update. Samples may come
+// in any order, ones older than the newest observation are weighted down on
+// arrival. UpdateAt and Update should not be mixed on one Feature.
+func (f *Feature) UpdateAt(sample *samples.Sample, at time.Time) {
+	if f.halfLife <= 0 {
+		panic("half-life has to be set before time-decayed update")
+	}
+	value, vector := f.sampleValue(sample)
+
+	if f.weight == 0 {
+		if vector != nil {
+			f.updateVectorAt(vector, 0, 1.0)
+		}
+		f.mean = value
+		f.min = value
+		f.max = value
+		f.variance = 0.0
+		f.std = 0.0
+		f.weight = 1.0
+		f.updated = at
+		f.estimate()
+		return
+	}
+
+	w := 1.0
+	if at.After(f.updated) {
+		f.weight *= f.decay(at.Sub(f.updated))
+		f.updated = at
+	} else {
+		w = f.decay(f.updated.Sub(at))
+	}
+
+	if vector != nil {
+		f.updateVectorAt(vector, f.weight, w)
+	}
+
+	total := f.weight + w
+	delta := value - f.mean
+	f.mean += delta * w / total
+	f.variance = (f.weight*f.variance + w*delta*(value-f.mean)) / total
+	f.std = math.Sqrt(f.variance)
+	f.weight = total
+
+	if value > f.max {
+		f.max = value
+	}
+	if value < f.min {
+		f.min = value
+	}
+	f.estimate()
+}
+
+func (f *Feature) decay(elapsed time.Duration) float64 {
+	return math.Pow(0.5, float64(elapsed)/float64(f.halfLife))
 }
 
 func (f *Feature) Value() float64 {
@@ -116,17 +279,21 @@ func (f *Feature) Max() float64 {
 }
 
 func (f *Feature) Score(other *Feature) float64 {
-	return stat.StdScore(other.Value(), f.mean, f.std)
+	if f.means != nil && other.means != nil {
+		return f.metric.distance(f.means, f.variances, other.means)
+	}
+	if f.scale == 0 && other.Value() == f.location {
+		return 0
+	}
+	return stat.StdScore(other.Value(), f.location, f.scale)
 }
 
 type FeatureMap map[FeatureType]*Feature
 
 func (m FeatureMap) GoString() string {
 	var ftrStrings []string
-	for ftrType, ftr := range m {
-		if FeatureFlags[ftrType] {
-			ftrStrings = append(ftrStrings, ftr.String())
-		}
+	for _, ftr := range m {
+		ftrStrings = append(ftrStrings, ftr.String())
 	}
 	return fmt.Sprintf("<%T %s>", m, strings.Join(ftrStrings, ", "))
 }
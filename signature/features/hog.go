@@ -8,16 +8,51 @@ import (
 	"math"
 )
 
+const hogBins = 90
+
 func NewHOGFeature() *Feature {
 	return &Feature{fType: HOGFeatureType, function: histogramOfGradients}
 }
 
+func NewHOGHistogramFeature() *Feature {
+	return &Feature{
+		fType:   HOGHistogramFeatureType,
+		vector:  gradientHistogram,
+		summary: meanGradientAngle,
+		metric:  ChiSquareMetric,
+	}
+}
+
 func histogramOfGradients(sample *samples.Sample) float64 {
 	if sample.Empty() {
 		panic(fmt.Sprintf("empty mat in %#v", sample))
 	} else if gocv.CountNonZero(sample.Mat()) == 0 {
 		return 0.0
 	}
+	return meanGradientAngle(gradientHistogram(sample))
+}
+
+func meanGradientAngle(histogram []float64) float64 {
+	var total float64
+	for _, h := range histogram {
+		total += h
+	}
+	if total == 0 {
+		return 0.0
+	}
+	values := make([]float64, len(histogram))
+	for i := range values {
+		values[i] = float64(i * 180 / len(histogram))
+	}
+	return stat.Mean(values, histogram)
+}
+
+func gradientHistogram(sample *samples.Sample) []float64 {
+	if sample.Empty() {
+		panic(fmt.Sprintf("empty mat in %#v", sample))
+	} else if gocv.CountNonZero(sample.Mat()) == 0 {
+		return make([]float64, hogBins)
+	}
 	sobelX := gocv.NewMat()
 	sobelY := gocv.NewMat()
 	gocv.Sobel(sample.Mat(), &sobelX, gocv.MatTypeCV32F,
@@ -59,16 +94,13 @@ func histogramOfGradients(sample *samples.Sample) float64 {
 	for _, m := range bins {
 		total += m
 	}
-	weights := make([]float64, 90)
-	values := make([]float64, 90)
-	for i := 0; i < 90; i++ {
+	weights := make([]float64, hogBins)
+	for i := 0; i < hogBins; i++ {
 		if total > 0 {
 			weights[i] = bins[i] / total
 		} else {
 			weights[i] = 0.0
 		}
-		values[i] = float64(i * 2)
 	}
-	value := stat.Mean(values, weights)
-	return value
+	return weights
 }
@@ -8,7 +8,11 @@ const XMassCenter = 0
 const YMassCenter = 1
 
 func NewMassCenterFeature(pos int) *Feature {
-	return &Feature{fType: MassCenterXFeatureType, function: massCenter(pos)}
+	fType := MassCenterXFeatureType
+	if pos == YMassCenter {
+		fType = MassCenterYFeatureType
+	}
+	return &Feature{fType: fType, function: massCenter(pos)}
 }
 
 func massCenter(pos int) func(sample *samples.Sample) float64 {
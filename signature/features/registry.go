@@ -0,0 +1,171 @@
+package features
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/samples"
+	"sync"
+)
+
+// AreaMask is a set of signature areas a feature applies to. Bits follow the
+// order of signature.AreaType.
+type AreaMask uint8
+
+const (
+	BasicArea AreaMask = 1 << iota
+	RowArea
+	ColArea
+	GridArea
+)
+
+const AllAreas = BasicArea | RowArea | ColArea | GridArea
+
+type registration struct {
+	name        string
+	constructor func() *Feature
+	areas       AreaMask
+	optional    bool
+}
+
+var (
+	registry      []registration
+	registryMutex sync.RWMutex
+)
+
+func init() {
+	for _, r := range []struct {
+		fType       FeatureType
+		name        string
+		constructor func() *Feature
+		areas       AreaMask
+		optional    bool
+	}{
+		{LengthFeatureType, "LengthFeature", NewLengthFeature, AllAreas, false},
+		{GradientFeatureType, "GradientFeature", NewGradientFeature, AllAreas, false},
+		{AspectFeatureType, "AspectFeature", NewAspectFeature, BasicArea, false},
+		{HOGFeatureType, "HOGFeature", NewHOGFeature, GridArea, false},
+		{CornersFeatureType, "CornersFeature", NewCornersFeature, 0, true},
+		{MassCenterXFeatureType, "MassCenterXFeature",
+			func() *Feature { return NewMassCenterFeature(XMassCenter) }, BasicArea | RowArea, false},
+		{MassCenterYFeatureType, "MassCenterYFeature",
+			func() *Feature { return NewMassCenterFeature(YMassCenter) }, BasicArea | ColArea, false},
+		{HOGHistogramFeatureType, "HOGHistogramFeature", NewHOGHistogramFeature, GridArea, true},
+		{SkewFeatureType, "SkewFeature", NewSkewFeature, BasicArea, true},
+	} {
+		if t := register(r.name, r.constructor, r.areas, r.optional); t != r.fType {
+			panic(fmt.Sprintf("%s registered as %d instead of %d", r.name, t, r.fType))
+		}
+	}
+}
+
+// Register adds a feature named name to the registry and returns its type.
+// Default model configurations created afterwards include the feature in
+// every area of areas. Features created by constructor get the returned type,
+// so constructor may use NewFeature or NewVectorFeature. Register is meant to
+// be called from init functions and panics if name is already registered.
+func Register(name string, constructor func() *Feature, areas AreaMask) FeatureType {
+	return register(name, constructor, areas, false)
+}
+
+// RegisterOptional is like Register, but the feature is left out of default
+// model configurations and has to be listed in a configuration explicitly.
+func RegisterOptional(name string, constructor func() *Feature, areas AreaMask) FeatureType {
+	return register(name, constructor, areas, true)
+}
+
+func register(name string, constructor func() *Feature, areas AreaMask, optional bool) FeatureType {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	for _, r := range registry {
+		if r.name == name {
+			panic(fmt.Sprintf("feature %s registered twice", name))
+		}
+	}
+	registry = append(registry, registration{name: name, constructor: constructor, areas: areas, optional: optional})
+	return FeatureType(len(registry) - 1)
+}
+
+func registered(t FeatureType) (registration, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	if t < 0 || int(t) >= len(registry) {
+		return registration{}, false
+	}
+	return registry[t], true
+}
+
+// Registered returns all registered feature types in order of registration.
+func Registered() []FeatureType {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	types := make([]FeatureType, len(registry))
+	for i := range registry {
+		types[i] = FeatureType(i)
+	}
+	return types
+}
+
+// Areas returns the set of areas feature type t applies to.
+func (t FeatureType) Areas() AreaMask {
+	r, _ := registered(t)
+	return r.areas
+}
+
+// Optional reports whether feature type t is left out of default model
+// configurations.
+func (t FeatureType) Optional() bool {
+	r, _ := registered(t)
+	return r.optional
+}
+
+// New creates a feature of registered type t.
+func New(t FeatureType) (*Feature, error) {
+	r, ok := registered(t)
+	if !ok {
+		return nil, fmt.Errorf("unknown feature type %d", int(t))
+	}
+	f := r.constructor()
+	f.fType = t
+	return f, nil
+}
+
+// NewFeatureMap creates a map of all registered features that apply to any of
+// given areas and are not optional.
+func NewFeatureMap(areas AreaMask) FeatureMap {
+	m := make(FeatureMap)
+	for _, t := range Registered() {
+		if t.Areas()&areas != 0 && !t.Optional() {
+			m[t], _ = New(t)
+		}
+	}
+	return m
+}
+
+func NewFeature(function func(sample *samples.Sample) float64) *Feature {
+	return &Feature{function: function}
+}
+
+// NewVectorFeature creates a feature computing a vector of values per sample.
+// Its scalar value, used for filtering, is summary of the vector, and metric
+// is used to score it against templates.
+func NewVectorFeature(
+	vector func(sample *samples.Sample) []float64,
+	summary func(vector []float64) float64,
+	metric Metric,
+) *Feature {
+	return &Feature{vector: vector, summary: summary, metric: metric}
+}
+
+func ParseFeatureType(name string) (FeatureType, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	for i, r := range registry {
+		if r.name == name {
+			return FeatureType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown feature type %q", name)
+}
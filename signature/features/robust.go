@@ -0,0 +1,154 @@
+package features
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"sort"
+)
+
+// Estimator selects how Feature.Score estimates location and scale of
+// feature values.
+type Estimator int
+
+const (
+	// MeanEstimator uses mean and standard deviation gathered by Update.
+	MeanEstimator Estimator = iota
+	// MedianEstimator uses median and scaled median absolute deviation of
+	// observations, or scaled mean absolute deviation if the median one is 0.
+	MedianEstimator
+	// TrimmedEstimator uses mean and standard deviation of observations with
+	// trim fraction of the lowest and the highest ones left out.
+	TrimmedEstimator
+)
+
+// madScale makes MAD a consistent estimator of standard deviation of normally
+// distributed values.
+const madScale = 1.4826
+
+// meanADScale makes mean absolute deviation a consistent estimator of
+// standard deviation of normally distributed values, sqrt(π/2).
+const meanADScale = 1.2533
+
+func (e Estimator) String() string {
+	switch e {
+	case MeanEstimator:
+		return "MeanEstimator"
+	case MedianEstimator:
+		return "MedianEstimator"
+	case TrimmedEstimator:
+		return "TrimmedEstimator"
+	default:
+		return fmt.Sprintf("Estimator(%d)", int(e))
+	}
+}
+
+func (e Estimator) MarshalText() ([]byte, error) {
+	if e < MeanEstimator || e > TrimmedEstimator {
+		return nil, fmt.Errorf("unknown estimator %d", int(e))
+	}
+	return []byte(e.String()), nil
+}
+
+func (e *Estimator) UnmarshalText(text []byte) error {
+	for t := MeanEstimator; t <= TrimmedEstimator; t++ {
+		if t.String() == string(text) {
+			*e = t
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown estimator %q", string(text))
+}
+
+// SetEstimator sets estimator of location and scale used by Score. Trim is
+// the fraction of observations left out at each end by TrimmedEstimator.
+// Robust estimators need observations, so features updated with UpdateAt
+// keep using mean and standard deviation.
+func (f *Feature) SetEstimator(estimator Estimator, trim float64) {
+	f.estimator = estimator
+	f.trim = trim
+	f.estimate()
+}
+
+func (f *Feature) Estimator() Estimator {
+	return f.estimator
+}
+
+// SetPrior sets prior of feature variance learned from a population of
+// users. Scale used by Score becomes the MAP estimate
+//
+//	sqrt((strength·variance + n·scale²) / (strength + n)),
+//
+// where n is the number of observations, so features that did not vary
+// between few enrollment samples still get a sensible scale. Strength 0
+// removes the prior.
+func (f *Feature) SetPrior(variance, strength float64) {
+	f.priorVariance = variance
+	f.priorStrength = strength
+	f.estimate()
+}
+
+func (f *Feature) Prior() (variance, strength float64) {
+	return f.priorVariance, f.priorStrength
+}
+
+func (f *Feature) estimate() {
+	f.estimateRaw()
+	if f.priorStrength > 0 {
+		n := float64(len(f.observations))
+		if n == 0 {
+			n = math.Max(f.weight, 1)
+		}
+		f.scale = math.Sqrt((f.priorStrength*f.priorVariance + n*f.scale*f.scale) / (f.priorStrength + n))
+	}
+}
+
+func (f *Feature) estimateRaw() {
+	if f.estimator == MeanEstimator || len(f.observations) == 0 {
+		f.location, f.scale = f.mean, f.std
+		return
+	}
+	sorted := append([]float64(nil), f.observations...)
+	sort.Float64s(sorted)
+	switch f.estimator {
+	case MedianEstimator:
+		f.location = median(sorted)
+		deviations := make([]float64, len(sorted))
+		for i, v := range sorted {
+			deviations[i] = math.Abs(v - f.location)
+		}
+		sort.Float64s(deviations)
+		f.scale = madScale * median(deviations)
+		if f.scale == 0 {
+			// more than half the observations are equal, which is common
+			// with few samples, fall back to mean absolute deviation
+			f.scale = meanADScale * stat.Mean(deviations, nil)
+		}
+	case TrimmedEstimator:
+		k := int(f.trim * float64(len(sorted)))
+		if 2*k >= len(sorted) {
+			k = (len(sorted) - 1) / 2
+		}
+		var variance float64
+		f.location, variance = stat.PopMeanVariance(sorted[k:len(sorted)-k], nil)
+		f.scale = math.Sqrt(variance)
+	}
+}
+
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// Location is the estimate of feature value used by Score.
+func (f *Feature) Location() float64 {
+	return f.location
+}
+
+// Scale is the estimate of feature value spread used by Score.
+func (f *Feature) Scale() float64 {
+	return f.scale
+}
@@ -0,0 +1,14 @@
+package features
+
+import "github.com/radekwlsk/handauth/samples"
+
+// NewSkewFeature creates feature of the angle samples were leveled by in
+// preprocessing, see samples.Sample.Skew. It is 0 for samples preprocessed
+// without samples.PreprocessConfig.Deskew.
+func NewSkewFeature() *Feature {
+	return &Feature{fType: SkewFeatureType, function: skew}
+}
+
+func skew(sample *samples.Sample) float64 {
+	return sample.Skew()
+}
@@ -0,0 +1,147 @@
+package features
+
+import (
+	"fmt"
+	"math"
+)
+
+// Metric compares a vector of a sample with per dimension statistics of a
+// vector feature.
+type Metric int
+
+const (
+	// StdScoreMetric is the mean absolute z-score of dimensions with
+	// non-zero variance.
+	StdScoreMetric Metric = iota
+	// ChiSquareMetric is the chi-square distance between histograms.
+	ChiSquareMetric
+	// BhattacharyyaMetric is the Bhattacharyya distance between histograms.
+	BhattacharyyaMetric
+)
+
+var metricNames = []string{
+	"StdScore",
+	"ChiSquare",
+	"Bhattacharyya",
+}
+
+func (m Metric) String() string {
+	return metricNames[m]
+}
+
+func (m Metric) MarshalText() ([]byte, error) {
+	if m < 0 || int(m) >= len(metricNames) {
+		return nil, fmt.Errorf("unknown metric %d", int(m))
+	}
+	return []byte(m.String()), nil
+}
+
+func (m *Metric) UnmarshalText(text []byte) error {
+	for i, name := range metricNames {
+		if name == string(text) {
+			*m = Metric(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown metric %q", string(text))
+}
+
+func (m Metric) distance(means, variances, vector []float64) float64 {
+	if len(means) != len(vector) {
+		panic(fmt.Sprintf("comparing vectors of %d and %d dimensions", len(means), len(vector)))
+	}
+	switch m {
+	case StdScoreMetric:
+		return stdScoreDistance(means, variances, vector)
+	case ChiSquareMetric:
+		return chiSquareDistance(means, vector)
+	case BhattacharyyaMetric:
+		return bhattacharyyaDistance(means, vector)
+	default:
+		panic(fmt.Sprintf("unknown metric %d", int(m)))
+	}
+}
+
+func stdScoreDistance(means, variances, vector []float64) float64 {
+	var sum float64
+	var n int
+	for i, v := range vector {
+		if variances[i] > 0 {
+			sum += math.Abs(v-means[i]) / math.Sqrt(variances[i])
+			n++
+		}
+	}
+	if n == 0 {
+		return 0.0
+	}
+	return sum / float64(n)
+}
+
+func chiSquareDistance(p, q []float64) float64 {
+	var d float64
+	for i := range p {
+		if s := p[i] + q[i]; s > 0 {
+			d += (p[i] - q[i]) * (p[i] - q[i]) / s
+		}
+	}
+	return d / 2
+}
+
+func bhattacharyyaDistance(p, q []float64) float64 {
+	var bc, sp, sq float64
+	for i := range p {
+		bc += math.Sqrt(p[i] * q[i])
+		sp += p[i]
+		sq += q[i]
+	}
+	if sp == 0 && sq == 0 {
+		return 0.0
+	}
+	return -math.Log(math.Max(bc, 1e-12))
+}
+
+func (f *Feature) updateVector(vector []float64, nSamples int) {
+	if nSamples == 1 || len(f.means) != len(vector) {
+		f.means = append([]float64(nil), vector...)
+		f.variances = make([]float64, len(vector))
+		return
+	}
+	n := float64(nSamples)
+	for i, v := range vector {
+		delta := v - f.means[i]
+		f.variances[i] = (n - 1) / n * (f.variances[i] + delta*delta/n)
+		f.means[i] += delta / n
+	}
+}
+
+func (f *Feature) updateVectorAt(vector []float64, weight, w float64) {
+	if weight == 0 || len(f.means) != len(vector) {
+		f.means = append([]float64(nil), vector...)
+		f.variances = make([]float64, len(vector))
+		return
+	}
+	total := weight + w
+	for i, v := range vector {
+		delta := v - f.means[i]
+		f.means[i] += delta * w / total
+		f.variances[i] = (weight*f.variances[i] + w*delta*(v-f.means[i])) / total
+	}
+}
+
+func (f *Feature) SetMetric(metric Metric) {
+	f.metric = metric
+}
+
+func (f *Feature) Metric() Metric {
+	return f.metric
+}
+
+// Vector returns per dimension means of a vector feature, nil for scalar
+// features.
+func (f *Feature) Vector() []float64 {
+	return f.means
+}
+
+func (f *Feature) VectorVar() []float64 {
+	return f.variances
+}
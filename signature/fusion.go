@@ -0,0 +1,229 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/signature/classifier"
+	"io"
+	"math"
+	"sort"
+)
+
+// Fusion combines area scores of a sample into a single score. Like area
+// scores, lower fused scores mean samples more similar to the template, and
+// a sample is accepted if its fused score is below the threshold. Areas with
+// NaN scores, e.g. ones whose every cell was removed by AreaFilter, are left
+// out.
+type Fusion interface {
+	Fuse(score Score, weights AreaThresholdWeights) (float64, error)
+}
+
+// Accept reports whether fused score of s is below threshold t.
+func (s Score) Accept(t float64, fusion Fusion, weights AreaThresholdWeights) (bool, error) {
+	fused, err := fusion.Fuse(s, weights)
+	if err != nil {
+		return false, err
+	}
+	return fused < t, nil
+}
+
+func weight(weights AreaThresholdWeights, area AreaType) float64 {
+	if w, ok := weights[area]; ok {
+		return w
+	}
+	return 1.0
+}
+
+// MaxFusion takes the highest weighted area score, so a sample is rejected
+// as soon as any area fails, like Score.Check.
+type MaxFusion struct{}
+
+func (MaxFusion) Fuse(score Score, weights AreaThresholdWeights) (float64, error) {
+	fused := math.NaN()
+	for area, s := range score {
+		if !math.IsNaN(s) && !(s*weight(weights, area) <= fused) {
+			fused = s * weight(weights, area)
+		}
+	}
+	return fused, nil
+}
+
+// MinFusion takes the lowest weighted area score, so a sample is accepted
+// if any area passes.
+type MinFusion struct{}
+
+func (MinFusion) Fuse(score Score, weights AreaThresholdWeights) (float64, error) {
+	fused := math.NaN()
+	for area, s := range score {
+		if !math.IsNaN(s) && !(s*weight(weights, area) >= fused) {
+			fused = s * weight(weights, area)
+		}
+	}
+	return fused, nil
+}
+
+// WeightedSumFusion takes the weighted mean of area scores.
+type WeightedSumFusion struct{}
+
+func (WeightedSumFusion) Fuse(score Score, weights AreaThresholdWeights) (float64, error) {
+	var sum, total float64
+	for area, s := range score {
+		if !math.IsNaN(s) {
+			w := weight(weights, area)
+			sum += w * s
+			total += w
+		}
+	}
+	if total == 0 {
+		return math.NaN(), nil
+	}
+	return sum / total, nil
+}
+
+// ProductFusionFloor is the lowest area score ProductFusion multiplies.
+const ProductFusionFloor = 1e-3
+
+// ProductFusion takes the weighted geometric mean of area scores, the
+// product of scores raised to their weights, normalised so it stays on the
+// scale of area scores. Area scores are floored at ProductFusionFloor, so a
+// single area scoring 0, or below 0 once normalised, does not zero the
+// product and accept the sample whatever its other areas score.
+type ProductFusion struct{}
+
+func (ProductFusion) Fuse(score Score, weights AreaThresholdWeights) (float64, error) {
+	var logSum, total float64
+	for area, s := range score {
+		if !math.IsNaN(s) {
+			w := weight(weights, area)
+			logSum += w * math.Log(math.Max(s, ProductFusionFloor))
+			total += w
+		}
+	}
+	if total == 0 {
+		return math.NaN(), nil
+	}
+	return math.Exp(logSum / total), nil
+}
+
+// LogisticFusion is a logistic regression trained on area scores of genuine
+// samples and forgeries with TrainLogisticFusion. Fused score is the
+// log-odds of the sample being a forgery, so thresholds are usually around
+// 0. Area weights are ignored, as the regression learns its own. Like in
+// training, scores missing any of the areas or with NaN in them are not
+// fused, their fused score is NaN, so they are never accepted. In JSON it
+// reads:
+//
+//	{"areas": ["BasicArea", "RowArea", "ColArea", "GridArea"],
+//	 "weights": [1.2, 0.4, 0.3, 2.1], "bias": -3.5}
+type LogisticFusion struct {
+	Areas []AreaType `json:"areas"`
+	classifier.Logistic
+}
+
+// TrainLogisticFusion trains LogisticFusion on area scores of genuine and
+// forgery samples. Scores with NaN or missing areas are skipped.
+func TrainLogisticFusion(genuine, forgery []Score, l2 float64) (*LogisticFusion, error) {
+	areaSet := make(map[AreaType]bool)
+	for _, s := range append(append([]Score(nil), genuine...), forgery...) {
+		for area := range s {
+			areaSet[area] = true
+		}
+	}
+	fusion := &LogisticFusion{}
+	for area := range areaSet {
+		fusion.Areas = append(fusion.Areas, area)
+	}
+	sort.Slice(fusion.Areas, func(i, j int) bool { return fusion.Areas[i] < fusion.Areas[j] })
+
+	var x [][]float64
+	var y []bool
+	add := func(scores []Score, isForgery bool) {
+		for _, s := range scores {
+			if v, ok := fusion.vector(s); ok {
+				x = append(x, v)
+				y = append(y, isForgery)
+			}
+		}
+	}
+	add(genuine, false)
+	add(forgery, true)
+	if len(x) == 0 {
+		return nil, fmt.Errorf("no complete scores to train fusion on")
+	}
+	logistic, err := classifier.TrainLogistic(x, y, l2)
+	if err != nil {
+		return nil, err
+	}
+	fusion.Logistic = *logistic
+	return fusion, nil
+}
+
+func (f *LogisticFusion) vector(score Score) ([]float64, bool) {
+	v := make([]float64, len(f.Areas))
+	for i, area := range f.Areas {
+		s, ok := score[area]
+		if !ok || math.IsNaN(s) || math.IsInf(s, 0) {
+			return nil, false
+		}
+		v[i] = s
+	}
+	return v, true
+}
+
+func (f *LogisticFusion) Fuse(score Score, _ AreaThresholdWeights) (float64, error) {
+	if len(f.Weights) != len(f.Areas) {
+		return 0, fmt.Errorf("logistic fusion of %d areas has %d weights", len(f.Areas), len(f.Weights))
+	}
+	v, ok := f.vector(score)
+	if !ok {
+		return math.NaN(), nil
+	}
+	z := f.Bias
+	for i, s := range v {
+		z += f.Weights[i] * s
+	}
+	return z, nil
+}
+
+func (f *LogisticFusion) validate() error {
+	if len(f.Weights) != len(f.Areas) {
+		return fmt.Errorf("logistic fusion of %d areas has %d weights", len(f.Areas), len(f.Weights))
+	}
+	return nil
+}
+
+func ReadLogisticFusion(filename string) (*LogisticFusion, error) {
+	fusion := new(LogisticFusion)
+	if err := readJSON(filename, fusion); err != nil {
+		return nil, err
+	}
+	return fusion, nil
+}
+
+func LoadLogisticFusion(r io.Reader) (*LogisticFusion, error) {
+	fusion := new(LogisticFusion)
+	if err := loadJSON(r, fusion); err != nil {
+		return nil, err
+	}
+	return fusion, nil
+}
+
+func (f *LogisticFusion) Write(filename string) error {
+	return writeJSON(filename, f)
+}
+
+// ParseFusion returns rule-based fusion of given name: max, min, sum or
+// product.
+func ParseFusion(name string) (Fusion, error) {
+	switch name {
+	case "max":
+		return MaxFusion{}, nil
+	case "min":
+		return MinFusion{}, nil
+	case "sum":
+		return WeightedSumFusion{}, nil
+	case "product":
+		return ProductFusion{}, nil
+	default:
+		return nil, fmt.Errorf("unknown fusion %q", name)
+	}
+}
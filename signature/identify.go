@@ -0,0 +1,60 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/samples"
+	"math"
+	"sort"
+)
+
+// Candidate is an enrolled user ranked by Identify.
+type Candidate struct {
+	Id uint16 `json:"id"`
+	// Score of the sample against template of the user, normalised like
+	// UserModel.Score.
+	Score Score `json:"score"`
+	// Value is the fused Score candidates are ranked by, lower ranks higher.
+	Value float64 `json:"value"`
+}
+
+// Identify scores sample against every template, see UserModel.Score, and
+// returns candidates ranked by scores fused with fusion, the most likely
+// writer first. Features of the sample are extracted once for all templates
+// of the same grid. Candidates with NaN values are ranked last. Templates
+// without enrolled Model are skipped.
+func Identify(sample *samples.Sample, templates []*UserModel, fusion Fusion) ([]Candidate, error) {
+	if fusion == nil {
+		fusion = MaxFusion{}
+	}
+	fs := &featureSet{sample: sample}
+	candidates := make([]Candidate, 0, len(templates))
+	for _, um := range templates {
+		if um.Model == nil {
+			continue
+		}
+		score, _, _ := um.scoreStyle(fs.score)
+		value, err := fusion.Fuse(score, um.Model.config.Weights())
+		if err != nil {
+			return nil, fmt.Errorf("user %d: %v", um.Id, err)
+		}
+		candidates = append(candidates, Candidate{Id: um.Id, Score: score, Value: value})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].Value, candidates[j].Value
+		if math.IsNaN(b) {
+			return !math.IsNaN(a)
+		}
+		return a < b
+	})
+	return candidates, nil
+}
+
+// Rank returns 1-based rank of user id in candidates, 0 if id is not there.
+func Rank(candidates []Candidate, id uint16) int {
+	for i, c := range candidates {
+		if c.Id == id {
+			return i + 1
+		}
+	}
+	return 0
+}
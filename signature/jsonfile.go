@@ -0,0 +1,74 @@
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// validator is a model saved as JSON that checks itself once loaded.
+type validator interface {
+	validate() error
+}
+
+// loadJSON decodes v from r and validates it.
+func loadJSON(r io.Reader, v validator) error {
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return err
+	}
+	return v.validate()
+}
+
+// readJSON decodes v from file filename and validates it.
+func readJSON(filename string, v validator) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := loadJSON(f, v); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return nil
+}
+
+// writeJSON writes v as indented JSON to file filename with WriteFileAtomic.
+func writeJSON(filename string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filename, b)
+}
+
+// WriteFileAtomic writes b to file filename. It writes a temporary file in
+// the same directory first and renames it, so the file is never left half
+// written.
+func WriteFileAtomic(filename string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-"+filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filename); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
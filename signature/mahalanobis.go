@@ -0,0 +1,181 @@
+package signature
+
+import (
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	"math"
+)
+
+// minShrinkage keeps the shrinkage covariance estimate invertible when the
+// estimated shrinkage intensity is 0 and there are fewer samples than
+// features.
+const minShrinkage = 0.01
+
+// scoreMahalanobis scores area of sample model s against template t with
+// Mahalanobis distance of the whole area feature vector. Covariance is
+// estimated from observations kept in template features and shrunk towards
+// its diagonal,
+//
+//	S* = λ·diag(S) + (1-λ)·S,
+//
+// with λ set in model configuration or, if it is 0, estimated as in Schäfer
+// and Strimmer (2005). The distance is divided by the number of features and
+// square rooted, so with λ = 1 the score is the root mean square of feature
+// z-scores and stays comparable with scoreBasic and friends. Features that
+// did not vary between enrollment samples are skipped. Templates without
+// observations of at least 2 samples, e.g. loaded from old templates or
+// built with ExtractAt, are scored with z-scores instead.
+func scoreMahalanobis(t, s *Model, area AreaType) float64 {
+	afs := t.areaFeatures(area)
+	if len(afs) == 0 {
+		return math.NaN()
+	}
+	n := len(afs[0].ftr.Observations())
+
+	var xs [][]float64
+	var ys, variances []float64
+	for _, af := range afs {
+		observations := af.ftr.Observations()
+		if n < 2 || len(observations) != n {
+			return scoreStd(t, s, area)
+		}
+		sampleFtr := s.feature(area, af)
+		if sampleFtr == nil {
+			continue
+		}
+		mean, variance := stat.MeanVariance(observations, nil)
+		if !(variance > 0) {
+			continue
+		}
+		xc := make([]float64, n)
+		for k, o := range observations {
+			xc[k] = o - mean
+		}
+		xs = append(xs, xc)
+		ys = append(ys, sampleFtr.Value()-mean)
+		variances = append(variances, variance)
+	}
+	p := len(xs)
+	if p == 0 {
+		return math.NaN()
+	}
+
+	lambda := t.config.Shrinkage
+	if lambda == 0 {
+		lambda = shrinkageIntensity(xs, variances, n)
+	}
+	lambda = math.Max(lambda, minShrinkage)
+
+	// S* = A + c·Xᵀ·X with A = λ·diag(S) and c = (1-λ)/(n-1), inverted with
+	// Woodbury identity so only an n×n system has to be solved
+	d := 0.0
+	ainv := make([]float64, p)
+	for j := range xs {
+		ainv[j] = 1 / (lambda * variances[j])
+		d += ys[j] * ys[j] * ainv[j]
+	}
+	if lambda < 1 {
+		c := (1 - lambda) / float64(n-1)
+		b := mat.NewVecDense(n, nil)
+		m := mat.NewSymDense(n, nil)
+		for k := 0; k < n; k++ {
+			bk := 0.0
+			for j := range xs {
+				bk += xs[j][k] * ainv[j] * ys[j]
+			}
+			b.SetVec(k, bk)
+			for l := k; l < n; l++ {
+				mkl := 0.0
+				for j := range xs {
+					mkl += xs[j][k] * xs[j][l] * ainv[j]
+				}
+				if k == l {
+					mkl += 1 / c
+				}
+				m.SetSym(k, l, mkl)
+			}
+		}
+		var chol mat.Cholesky
+		if !chol.Factorize(m) {
+			return math.NaN()
+		}
+		var x mat.VecDense
+		if err := chol.SolveVecTo(&x, b); err != nil {
+			return math.NaN()
+		}
+		d -= mat.Dot(b, &x)
+	}
+	return math.Sqrt(math.Max(d, 0) / float64(p))
+}
+
+// shrinkageIntensity estimates optimal intensity of shrinking sample
+// covariance of centered observations xs towards its diagonal, as the sum of
+// estimated variances of sample correlations divided by the sum of squared
+// sample correlations. Sums over all feature pairs are computed from the n×n
+// Gram matrix of standardized observations.
+func shrinkageIntensity(xs [][]float64, variances []float64, n int) float64 {
+	nf := float64(n)
+	z := make([][]float64, len(xs))
+	for j, xc := range xs {
+		std := math.Sqrt(variances[j])
+		z[j] = make([]float64, n)
+		for k, x := range xc {
+			z[j][k] = x / std
+		}
+	}
+
+	gram := make([][]float64, n)
+	for k := range gram {
+		gram[k] = make([]float64, n)
+		for l := 0; l <= k; l++ {
+			g := 0.0
+			for j := range z {
+				g += z[j][k] * z[j][l]
+			}
+			gram[k][l] = g
+		}
+	}
+	var sumGram2, sumDiagGram2 float64
+	for k := range gram {
+		for l := 0; l < k; l++ {
+			sumGram2 += 2 * gram[k][l] * gram[k][l]
+		}
+		sumGram2 += gram[k][k] * gram[k][k]
+		sumDiagGram2 += gram[k][k] * gram[k][k]
+	}
+
+	// terms of i = j pairs, excluded from both sums
+	var diagVar, diagW2 float64
+	for j := range z {
+		var w, w2 float64
+		for _, zk := range z[j] {
+			w += zk * zk
+			w2 += zk * zk * zk * zk
+		}
+		w /= nf
+		diagVar += w2 - nf*w*w
+		diagW2 += w * w
+	}
+
+	varR := nf / math.Pow(nf-1, 3) * (sumDiagGram2 - sumGram2/nf - diagVar)
+	sumR2 := math.Pow(nf/(nf-1), 2) * (sumGram2/(nf*nf) - diagW2)
+	if !(sumR2 > 0) {
+		return 1
+	}
+	return math.Min(1, math.Max(0, varR/sumR2))
+}
+
+func scoreStd(t, s *Model, area AreaType) float64 {
+	switch area {
+	case BasicAreaType:
+		return scoreBasic(t, s)
+	case GridAreaType:
+		return scoreGrid(t, s)
+	case RowAreaType:
+		return scoreRow(t, s)
+	case ColAreaType:
+		return scoreCol(t, s)
+	default:
+		return math.NaN()
+	}
+}
@@ -0,0 +1,132 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/signature/features"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Merge combines model enrolled from nSelf samples with other enrolled from
+// nOther samples. Both models need the same configuration and areas. Cells and
+// features that were filtered out of either model are removed from the
+// result, as statistics of the other one alone would not describe all
+// nSelf+nOther samples.
+func (model *Model) Merge(other *Model, nSelf, nOther int) error {
+	if nSelf < 1 || nOther < 1 {
+		return fmt.Errorf("both models have to be extracted from at least 1 sample")
+	}
+	if model.halfLife != 0 || other.halfLife != 0 {
+		return fmt.Errorf("cannot merge time-decayed models")
+	}
+	if model.config.Rows != other.config.Rows || model.config.Cols != other.config.Cols {
+		return fmt.Errorf("cannot merge %dx%d model with %dx%d model",
+			model.config.Rows, model.config.Cols, other.config.Rows, other.config.Cols)
+	}
+	if !model.config.equal(other.config) {
+		return fmt.Errorf("cannot merge models of different configurations")
+	}
+	if (model.basic == nil) != (other.basic == nil) ||
+		(model.grid == nil) != (other.grid == nil) ||
+		(model.row == nil) != (other.row == nil) ||
+		(model.col == nil) != (other.col == nil) {
+		return fmt.Errorf("cannot merge models extracted with different areas")
+	}
+
+	if (model.reference == nil) != (other.reference == nil) {
+		return fmt.Errorf("cannot merge registered model with unregistered one")
+	}
+	if model.reference != nil {
+		if err := model.reference.CheckMerge(other.reference); err != nil {
+			return err
+		}
+	}
+	pairs := model.mergedMaps(other)
+	for _, pair := range pairs {
+		if err := checkMergeFeatureMap(pair[0], pair[1], nSelf, nOther); err != nil {
+			return err
+		}
+	}
+
+	// nothing fails past this point, so the model is never left half merged
+	if model.reference != nil {
+		if err := model.reference.Merge(other.reference, nSelf, nOther); err != nil {
+			return err
+		}
+	}
+	for _, pair := range pairs {
+		if err := mergeFeatureMap(pair[0], pair[1], nSelf, nOther); err != nil {
+			return err
+		}
+	}
+	for rc := range model.grid {
+		if _, ok := other.grid[rc]; !ok {
+			delete(model.grid, rc)
+		}
+	}
+	for r := range model.row {
+		if _, ok := other.row[r]; !ok {
+			delete(model.row, r)
+		}
+	}
+	for c := range model.col {
+		if _, ok := other.col[c]; !ok {
+			delete(model.col, c)
+		}
+	}
+
+	w := []float64{float64(nSelf), float64(nOther)}
+	model.fieldArea = stat.Mean([]float64{model.fieldArea, other.fieldArea}, w)
+	model.rowArea = stat.Mean([]float64{model.rowArea, other.rowArea}, w)
+	model.colArea = stat.Mean([]float64{model.colArea, other.colArea}, w)
+	model.samples = nSelf + nOther
+	return nil
+}
+
+// mergedMaps returns feature maps of cells present in both models, paired
+// with the ones of other.
+func (model *Model) mergedMaps(other *Model) [][2]features.FeatureMap {
+	var pairs [][2]features.FeatureMap
+	if model.basic != nil {
+		pairs = append(pairs, [2]features.FeatureMap{model.basic, other.basic})
+	}
+	for rc, ftrMap := range model.grid {
+		if otherMap, ok := other.grid[rc]; ok {
+			pairs = append(pairs, [2]features.FeatureMap{ftrMap, otherMap})
+		}
+	}
+	for r, ftrMap := range model.row {
+		if otherMap, ok := other.row[r]; ok {
+			pairs = append(pairs, [2]features.FeatureMap{ftrMap, otherMap})
+		}
+	}
+	for c, ftrMap := range model.col {
+		if otherMap, ok := other.col[c]; ok {
+			pairs = append(pairs, [2]features.FeatureMap{ftrMap, otherMap})
+		}
+	}
+	return pairs
+}
+
+func checkMergeFeatureMap(m, other features.FeatureMap, nSelf, nOther int) error {
+	for ftrType, ftr := range m {
+		if otherFtr, ok := other[ftrType]; ok {
+			if err := ftr.CheckMerge(otherFtr, nSelf, nOther); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func mergeFeatureMap(m, other features.FeatureMap, nSelf, nOther int) error {
+	for ftrType, ftr := range m {
+		if otherFtr, ok := other[ftrType]; ok {
+			if err := ftr.Merge(otherFtr, nSelf, nOther); err != nil {
+				return err
+			}
+		} else {
+			delete(m, ftrType)
+		}
+	}
+	return nil
+}
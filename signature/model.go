@@ -9,26 +9,30 @@ import (
 	"math"
 	"os"
 	"strings"
+	"time"
 )
 
-var Debug = false
 var logger = log.New(os.Stdout, "[features] ", log.Lshortfile+log.Ltime)
 
-var AreaFlags = map[AreaType]bool{
-	BasicAreaType: true,
-	RowAreaType:   true,
-	ColAreaType:   true,
-	GridAreaType:  true,
-}
-
 type UserModel struct {
-	Id    uint16
-	Model *Model
+	Id    uint16 `json:"id"`
+	Model *Model `json:"model"`
+	// OneClass is the one-class classifier of the user, nil if not trained.
+	OneClass *OneClass `json:"one_class,omitempty"`
+	// References are enrollment samples extracted with Dichotomy.Extract.
+	References []*Model `json:"references,omitempty"`
+	// Norm normalises scores of Model, none if nil.
+	Norm *ScoreNorm `json:"norm,omitempty"`
+	// ZNorm normalises scores of Model against an impostor cohort, none if
+	// nil, see SetZNorm.
+	ZNorm *ScoreNorm `json:"znorm,omitempty"`
+	// Styles are templates of signature styles of the user, see
+	// Model.Styles, none if the user has a single style.
+	Styles []*Model `json:"styles,omitempty"`
 }
 
 type Model struct {
-	rows      uint16
-	cols      uint16
+	config    ModelConfig
 	basic     features.FeatureMap
 	grid      GridFeatureMap
 	row       RowFeatureMap
@@ -36,6 +40,11 @@ type Model struct {
 	fieldArea float64
 	rowArea   float64
 	colArea   float64
+	samples   int
+	halfLife  time.Duration
+	// reference is the mean image of enrollment samples questioned samples
+	// are registered to, nil unless config.Register is set.
+	reference *samples.Reference
 }
 
 func (model *Model) Basic() features.FeatureMap {
@@ -54,7 +63,24 @@ func (model *Model) Col(c int) features.FeatureMap {
 	return model.col[c]
 }
 
+// NewModel creates model of given grid size with DefaultModelConfig, or
+// a model with configuration and cells of template if it is not nil.
 func NewModel(rows, cols uint16, template *Model) *Model {
+	if template == nil {
+		return newModel(DefaultModelConfig(rows, cols), nil)
+	}
+	return newModel(template.config, template)
+}
+
+func NewModelFromConfig(config ModelConfig) (*Model, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return newModel(config, nil), nil
+}
+
+func newModel(config ModelConfig, template *Model) *Model {
+	rows, cols := config.Rows, config.Cols
 	var rowKeys, colKeys []int
 	var gridKeys [][2]int
 	if template == nil {
@@ -82,76 +108,58 @@ func NewModel(rows, cols uint16, template *Model) *Model {
 			gridKeys = append(gridKeys, rc)
 		}
 	}
-	return newModel(rows, cols, rowKeys, colKeys, gridKeys)
-}
 
-func newModel(rows, cols uint16, rowKeys, colKeys []int, gridKeys [][2]int) *Model {
 	var basic features.FeatureMap
 	var grid GridFeatureMap
 	var row RowFeatureMap
 	var col ColFeatureMap
 
-	if AreaFlags[BasicAreaType] {
-		basic = features.FeatureMap{
-			features.LengthFeatureType:      features.NewLengthFeature(),
-			features.GradientFeatureType:    features.NewGradientFeature(),
-			features.AspectFeatureType:      features.NewAspectFeature(),
-			features.MassCenterXFeatureType: features.NewMassCenterFeature(features.XMassCenter),
-			features.MassCenterYFeatureType: features.NewMassCenterFeature(features.YMassCenter),
-		}
+	if config.Has(BasicAreaType) {
+		basic = config.featureMap(BasicAreaType)
 	}
-	if AreaFlags[GridAreaType] {
+	if config.Has(GridAreaType) {
 		grid = make(GridFeatureMap)
 		for _, rc := range gridKeys {
-			grid[rc] = features.FeatureMap{
-				features.LengthFeatureType:   features.NewLengthFeature(),
-				features.HOGFeatureType:      features.NewHOGFeature(),
-				features.GradientFeatureType: features.NewGradientFeature(),
-			}
+			grid[rc] = config.featureMap(GridAreaType)
 		}
 	}
-	if AreaFlags[RowAreaType] {
+	if config.Has(RowAreaType) {
 		row = make(RowFeatureMap)
 		for _, r := range rowKeys {
-			row[r] = features.FeatureMap{
-				features.LengthFeatureType:      features.NewLengthFeature(),
-				features.GradientFeatureType:    features.NewGradientFeature(),
-				features.MassCenterXFeatureType: features.NewMassCenterFeature(features.XMassCenter),
-			}
+			row[r] = config.featureMap(RowAreaType)
 		}
 	}
-	if AreaFlags[ColAreaType] {
+	if config.Has(ColAreaType) {
 		col = make(ColFeatureMap)
 		for _, c := range colKeys {
-			col[c] = features.FeatureMap{
-				features.LengthFeatureType:      features.NewLengthFeature(),
-				features.GradientFeatureType:    features.NewGradientFeature(),
-				features.MassCenterYFeatureType: features.NewMassCenterFeature(features.YMassCenter),
-			}
+			col[c] = config.featureMap(ColAreaType)
 		}
 	}
 	return &Model{
-		basic: basic,
-		grid:  grid,
-		row:   row,
-		col:   col,
-		rows:  rows,
-		cols:  cols,
+		config: config,
+		basic:  basic,
+		grid:   grid,
+		row:    row,
+		col:    col,
 	}
 }
 
+func (model *Model) Config() ModelConfig {
+	return model.config
+}
+
 func (model *Model) GoString() string {
 	var sb strings.Builder
-	if AreaFlags[BasicAreaType] {
+	if model.config.Has(BasicAreaType) {
 		sb.WriteString(fmt.Sprintf("\t%#v\n", model.basic))
 	}
-	if AreaFlags[GridAreaType] {
+	if model.config.Has(GridAreaType) {
 		sb.WriteString(fmt.Sprintf("\t%#v\n", model.grid))
 	}
-	if AreaFlags[RowAreaType] {
+	if model.config.Has(RowAreaType) {
 		sb.WriteString(fmt.Sprintf("\t%#v\n", model.row))
 	}
-	if AreaFlags[ColAreaType] {
+	if model.config.Has(ColAreaType) {
 		sb.WriteString(fmt.Sprintf("\t%#v\n", model.col))
 	}
 	return fmt.Sprintf("<%T \n%s>", model, sb.String())
@@ -169,6 +177,50 @@ func (model *Model) ColsCount() int {
 	return len(model.col)
 }
 
+func (model *Model) SamplesCount() int {
+	return model.samples
+}
+
+// Reference returns the mean image of enrollment samples, nil unless the
+// model registers samples.
+func (model *Model) Reference() *samples.Reference {
+	return model.reference
+}
+
+// SetHalfLife turns on exponential forgetting of all model features, see
+// features.Feature.SetHalfLife. It has to be set before the first ExtractAt.
+func (model *Model) SetHalfLife(halfLife time.Duration) {
+	model.halfLife = halfLife
+	model.forEachFeature(func(ftr *features.Feature) {
+		ftr.SetHalfLife(halfLife)
+	})
+}
+
+func (model *Model) HalfLife() time.Duration {
+	return model.halfLife
+}
+
+func (model *Model) forEachFeature(fn func(ftr *features.Feature)) {
+	for _, ftr := range model.basic {
+		fn(ftr)
+	}
+	for _, ftrMap := range model.grid {
+		for _, ftr := range ftrMap {
+			fn(ftr)
+		}
+	}
+	for _, ftrMap := range model.row {
+		for _, ftr := range ftrMap {
+			fn(ftr)
+		}
+	}
+	for _, ftrMap := range model.col {
+		for _, ftr := range ftrMap {
+			fn(ftr)
+		}
+	}
+}
+
 type Score map[AreaType]float64
 
 type AreaThresholdWeights map[AreaType]float64
@@ -191,14 +243,12 @@ func (s Score) Check(t float64, weights AreaThresholdWeights) (bool, error) {
 func scoreBasic(t, s *Model) float64 {
 	ss := make([]float64, 0)
 	for ftrType, ftr := range t.basic {
-		if features.FeatureFlags[ftrType] {
-			if Debug {
-				logger.Printf("score basic %s: sample: %s, template: %s\n",
-					ftrType, s.basic[ftrType], ftr)
-			}
-			s := ftr.Score(s.basic[ftrType])
-			ss = append(ss, math.Abs(s))
+		if t.config.Debug {
+			logger.Printf("score basic %s: sample: %s, template: %s\n",
+				ftrType, s.basic[ftrType], ftr)
 		}
+		s := ftr.Score(s.basic[ftrType])
+		ss = append(ss, math.Abs(s))
 	}
 	return stat.Mean(ss, nil)
 }
@@ -207,14 +257,12 @@ func scoreGrid(t, s *Model) float64 {
 	gss := make([]float64, len(t.grid))
 	for rc, ftrMap := range t.grid {
 		for ftrType, ftr := range ftrMap {
-			if features.FeatureFlags[ftrType] {
-				if Debug {
-					logger.Printf("score grid (%d,%d) %s: sample: %s, template: %s\n",
-						rc[0], rc[1], ftrType, s.grid[rc][ftrType], ftr)
-				}
-				s := ftr.Score(s.grid[rc][ftrType])
-				gss = append(gss, math.Abs(s))
+			if t.config.Debug {
+				logger.Printf("score grid (%d,%d) %s: sample: %s, template: %s\n",
+					rc[0], rc[1], ftrType, s.grid[rc][ftrType], ftr)
 			}
+			s := ftr.Score(s.grid[rc][ftrType])
+			gss = append(gss, math.Abs(s))
 		}
 	}
 	return stat.Mean(gss, nil)
@@ -224,14 +272,12 @@ func scoreRow(t, s *Model) float64 {
 	rss := make([]float64, len(t.row))
 	for r, ftrMap := range t.row {
 		for ftrType, ftr := range ftrMap {
-			if features.FeatureFlags[ftrType] {
-				if Debug {
-					logger.Printf("score row %d %s: sample: %s, template: %s\n",
-						r, ftrType, s.row[r][ftrType], ftr)
-				}
-				s := ftr.Score(s.row[r][ftrType])
-				rss = append(rss, math.Abs(s))
+			if t.config.Debug {
+				logger.Printf("score row %d %s: sample: %s, template: %s\n",
+					r, ftrType, s.row[r][ftrType], ftr)
 			}
+			s := ftr.Score(s.row[r][ftrType])
+			rss = append(rss, math.Abs(s))
 		}
 	}
 	return stat.Mean(rss, nil)
@@ -241,66 +287,96 @@ func scoreCol(t, s *Model) float64 {
 	css := make([]float64, len(t.col))
 	for c, ftrMap := range t.col {
 		for ftrType, ftr := range ftrMap {
-			if features.FeatureFlags[ftrType] {
-				if Debug {
-					logger.Printf("score col %d %s: sample: %s, template: %s\n",
-						c, ftrType, s.col[c][ftrType], ftr)
-				}
-				s := ftr.Score(s.col[c][ftrType])
-				css = append(css, math.Abs(s))
+			if t.config.Debug {
+				logger.Printf("score col %d %s: sample: %s, template: %s\n",
+					c, ftrType, s.col[c][ftrType], ftr)
 			}
+			s := ftr.Score(s.col[c][ftrType])
+			css = append(css, math.Abs(s))
 		}
 	}
 	return stat.Mean(css, nil)
 }
 
-func (model *Model) getScoreFunc(area AreaType) (func(ftr1, ftr2 *Model) float64, bool) {
-	switch area {
-	case BasicAreaType:
-		return scoreBasic, true
-	case GridAreaType:
-		return scoreGrid, true
-	case RowAreaType:
-		return scoreRow, true
-	case ColAreaType:
-		return scoreCol, true
-	default:
-		return nil, false
-	}
+func (model *Model) Score(sample *samples.Sample) (Score, *Model) {
+	sample, done := model.registered(sample)
+	defer done()
+	pattern := NewModel(model.config.Rows, model.config.Cols, model)
+	pattern.extractPattern(sample)
+	return model.score(pattern), pattern
 }
 
-func (model *Model) Score(sample *samples.Sample) (Score, *Model) {
-	pattern := NewModel(model.rows, model.cols, model)
-	pattern.Extract(sample, 1)
+// extractPattern extracts questioned sample into an empty pattern model.
+// Unlike Extract it neither registers the sample nor keeps its image.
+func (model *Model) extractPattern(sample *samples.Sample) {
+	model.extract(sample, 1, func(ftr *features.Feature, s *samples.Sample) {
+		ftr.Update(s, 1)
+	})
+}
 
+// score scores features of a sample extracted in pattern against the model.
+func (model *Model) score(pattern *Model) Score {
 	score := make(Score)
 
-	for area, flag := range AreaFlags {
-		if scoreFunc, ok := model.getScoreFunc(area); flag && ok {
+	for area := range model.config.Areas {
+		if scoreFunc, ok := model.getScoreFunc(area); ok {
 			score[area] = scoreFunc(model, pattern)
 		}
 	}
 
-	return score, pattern
+	return score
 }
 
-func (model *Model) Extract(sample *samples.Sample, nSamples int) {
+// Extract updates the model with sample as nSamples-th one. It fails without
+// changing the model if the sample cannot be added to the reference image.
+func (model *Model) Extract(sample *samples.Sample, nSamples int) error {
+	sample, done := model.registered(sample)
+	defer done()
+	if err := model.addReference(sample, nSamples); err != nil {
+		return err
+	}
+	model.extract(sample, nSamples, func(ftr *features.Feature, s *samples.Sample) {
+		ftr.Update(s, nSamples)
+	})
+	return nil
+}
+
+// ExtractAt updates the model with sample taken at given time, weighting older
+// samples down according to the half-life set with SetHalfLife.
+func (model *Model) ExtractAt(sample *samples.Sample, at time.Time) error {
+	if model.halfLife <= 0 {
+		panic("half-life has to be set before time-decayed extraction")
+	}
+	sample, done := model.registered(sample)
+	defer done()
+	if err := model.addReference(sample, model.samples+1); err != nil {
+		return err
+	}
+	model.extract(sample, model.samples+1, func(ftr *features.Feature, s *samples.Sample) {
+		ftr.UpdateAt(s, at)
+	})
+	return nil
+}
+
+func (model *Model) extract(
+	sample *samples.Sample,
+	nSamples int,
+	update func(ftr *features.Feature, s *samples.Sample),
+) {
 	sample.Update()
+	model.samples = nSamples
 
-	if AreaFlags[BasicAreaType] {
-		for ftrType, ftr := range model.basic {
-			if features.FeatureFlags[ftrType] {
-				ftr.Update(sample, nSamples)
-			}
-		}
+	for _, ftr := range model.basic {
+		update(ftr, sample)
 	}
 
 	var sampleGrid *samples.SampleGrid
-	if AreaFlags[GridAreaType] || AreaFlags[RowAreaType] || AreaFlags[ColAreaType] {
-		if sample.Height() < int(model.rows)*2 {
-			sample.Enlarge(0, int(model.rows)*2, nil)
+	if model.config.hasGrid() {
+		rows, cols := model.config.Rows, model.config.Cols
+		if sample.Height() < int(rows)*2 {
+			sample.Enlarge(0, int(rows)*2, nil)
 		}
-		sampleGrid = samples.NewSampleGrid(sample, model.rows, model.cols)
+		sampleGrid = samples.NewSampleGrid(sample, rows, cols)
 
 		{
 			w := []float64{float64(nSamples - 1), 1}
@@ -312,39 +388,27 @@ func (model *Model) Extract(sample *samples.Sample, nSamples int) {
 			model.colArea = stat.Mean([]float64{model.colArea, ca}, w)
 		}
 
-		if AreaFlags[GridAreaType] {
-			for rc, ftrMap := range model.grid {
-				for ftrType, ftr := range ftrMap {
-					if features.FeatureFlags[ftrType] {
-						s := sampleGrid.At(rc[0], rc[1])
-						ftr.Update(s, nSamples)
-						s.Close()
-					}
-				}
+		for rc, ftrMap := range model.grid {
+			for _, ftr := range ftrMap {
+				s := sampleGrid.At(rc[0], rc[1])
+				update(ftr, s)
+				s.Close()
 			}
 		}
 
-		if AreaFlags[RowAreaType] {
-			for r, ftrMap := range model.row {
-				for ftrType, ftr := range ftrMap {
-					if features.FeatureFlags[ftrType] {
-						s := sampleGrid.At(r, -1)
-						ftr.Update(s, nSamples)
-						s.Close()
-					}
-				}
+		for r, ftrMap := range model.row {
+			for _, ftr := range ftrMap {
+				s := sampleGrid.At(r, -1)
+				update(ftr, s)
+				s.Close()
 			}
 		}
 
-		if AreaFlags[ColAreaType] {
-			for c, ftrMap := range model.col {
-				for ftrType, ftr := range ftrMap {
-					if features.FeatureFlags[ftrType] {
-						s := sampleGrid.At(-1, c)
-						ftr.Update(s, nSamples)
-						s.Close()
-					}
-				}
+		for c, ftrMap := range model.col {
+			for _, ftr := range ftrMap {
+				s := sampleGrid.At(-1, c)
+				update(ftr, s)
+				s.Close()
 			}
 		}
 	}
@@ -354,32 +418,34 @@ func (model *Model) AreaFilter(fieldThreshold float64, rowColThreshold float64)
 	if model.fieldArea == 0.0 {
 		return fmt.Errorf("at least one sample has to be extracted before filtering")
 	}
-	if !features.FeatureFlags[features.LengthFeatureType] {
-		return nil
-	}
-
 	fieldAreaLimit := model.fieldArea * fieldThreshold
 	rowAreaLimit := model.rowArea * rowColThreshold
 	colAreaLimit := model.colArea * rowColThreshold
 
-	for rc, ftrMap := range model.grid {
-		lnFtr := ftrMap[features.LengthFeatureType]
-		if lnFtr.Value() < fieldAreaLimit {
-			delete(model.grid, rc)
+	if model.config.HasFeature(GridAreaType, features.LengthFeatureType) {
+		for rc, ftrMap := range model.grid {
+			lnFtr := ftrMap[features.LengthFeatureType]
+			if lnFtr.Value() < fieldAreaLimit {
+				delete(model.grid, rc)
+			}
 		}
 	}
 
-	for r, ftrMap := range model.row {
-		lnFtr := ftrMap[features.LengthFeatureType]
-		if lnFtr.Value() < rowAreaLimit {
-			delete(model.row, r)
+	if model.config.HasFeature(RowAreaType, features.LengthFeatureType) {
+		for r, ftrMap := range model.row {
+			lnFtr := ftrMap[features.LengthFeatureType]
+			if lnFtr.Value() < rowAreaLimit {
+				delete(model.row, r)
+			}
 		}
 	}
 
-	for c, ftrMap := range model.col {
-		lnFtr := ftrMap[features.LengthFeatureType]
-		if lnFtr.Value() < colAreaLimit {
-			delete(model.col, c)
+	if model.config.HasFeature(ColAreaType, features.LengthFeatureType) {
+		for c, ftrMap := range model.col {
+			lnFtr := ftrMap[features.LengthFeatureType]
+			if lnFtr.Value() < colAreaLimit {
+				delete(model.col, c)
+			}
 		}
 	}
 	return nil
@@ -397,7 +463,7 @@ func (model *Model) StdFilter(threshold float64) error {
 
 	for rc, ftrMap := range model.grid {
 		for ftrType, ftr := range ftrMap {
-			if features.FeatureFlags[ftrType] && stdFilter(ftr, threshold) {
+			if stdFilter(ftr, threshold) {
 				delete(model.grid[rc], ftrType)
 				break
 			}
@@ -406,7 +472,7 @@ func (model *Model) StdFilter(threshold float64) error {
 
 	for r, ftrMap := range model.row {
 		for ftrType, ftr := range ftrMap {
-			if features.FeatureFlags[ftrType] && stdFilter(ftr, threshold) {
+			if stdFilter(ftr, threshold) {
 				delete(model.row[r], ftrType)
 				break
 			}
@@ -415,7 +481,7 @@ func (model *Model) StdFilter(threshold float64) error {
 
 	for c, ftrMap := range model.col {
 		for ftrType, ftr := range ftrMap {
-			if features.FeatureFlags[ftrType] && stdFilter(ftr, threshold) {
+			if stdFilter(ftr, threshold) {
 				delete(model.col[c], ftrType)
 				break
 			}
@@ -424,6 +490,22 @@ func (model *Model) StdFilter(threshold float64) error {
 	return nil
 }
 
+// Filter applies filters enabled in model configuration.
+func (model *Model) Filter() error {
+	if model.config.AreaFilter.Enabled {
+		err := model.AreaFilter(model.config.AreaFilter.FieldThreshold, model.config.AreaFilter.RowColThreshold)
+		if err != nil {
+			return err
+		}
+	}
+	if model.config.StdFilter.Enabled {
+		if err := model.StdFilter(model.config.StdFilter.Threshold); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (model *Model) FeaturesCount() (size uint64) {
 
 	for range model.basic {
@@ -0,0 +1,80 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/samples"
+	"math"
+)
+
+// ScoreNorm normalises area scores of a template to
+// (score - Location) / Scale. Areas without scale are left as they are and
+// missing locations are 0. In JSON it reads:
+//
+//	{"location": {"GridArea": 0.8}, "scale": {"BasicArea": 1.2, "GridArea": 0.3}}
+type ScoreNorm struct {
+	Location map[AreaType]float64 `json:"location,omitempty"`
+	Scale    map[AreaType]float64 `json:"scale"`
+}
+
+// Apply returns normalised copy of score.
+func (n *ScoreNorm) Apply(score Score) Score {
+	normalised := make(Score, len(score))
+	for area, s := range score {
+		if scale, ok := n.Scale[area]; ok {
+			s = (s - n.Location[area]) / scale
+		}
+		normalised[area] = s
+	}
+	return normalised
+}
+
+// MinLeaveOneOutSamples is the least number of samples LeaveOneOutNorm
+// needs, so every left out sample is scored against a template with variance.
+const MinLeaveOneOutSamples = 3
+
+// LeaveOneOutNorm scores every enrollment sample against a template built
+// with config and prior, which may be nil, from the other samples, and
+// returns ScoreNorm dividing area scores by the mean leave-one-out score of
+// the area. A threshold on normalised scores is then a multiple of the
+// user's typical genuine score, so consistent signers get tighter thresholds
+// than erratic ones. It needs at least MinLeaveOneOutSamples samples and
+// extracts each of them once per other sample.
+func LeaveOneOutNorm(config ModelConfig, ss []*samples.Sample, prior *VariancePrior) (*ScoreNorm, error) {
+	if len(ss) < MinLeaveOneOutSamples {
+		return nil, fmt.Errorf("leave-one-out needs at least %d samples, got %d", MinLeaveOneOutSamples, len(ss))
+	}
+	sums := make(map[AreaType]float64)
+	counts := make(map[AreaType]int)
+	for i, left := range ss {
+		template := newModel(config, nil)
+		n := 0
+		for j, sample := range ss {
+			if j != i {
+				n++
+				if err := template.Extract(sample, n); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := template.Filter(); err != nil {
+			return nil, err
+		}
+		if prior != nil {
+			template.SetVariancePrior(prior)
+		}
+		score, _ := template.Score(left)
+		for area, s := range score {
+			if !math.IsNaN(s) && !math.IsInf(s, 0) {
+				sums[area] += s
+				counts[area]++
+			}
+		}
+	}
+	norm := &ScoreNorm{Scale: make(map[AreaType]float64)}
+	for area, sum := range sums {
+		if mean := sum / float64(counts[area]); mean > 0 {
+			norm.Scale[area] = mean
+		}
+	}
+	return norm, nil
+}
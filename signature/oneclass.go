@@ -0,0 +1,114 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/signature/classifier"
+	"github.com/radekwlsk/handauth/signature/features"
+	"sort"
+)
+
+// DefaultOneClassNu is the default upper bound of the fraction of
+// enrollment samples left outside the one-class model.
+const DefaultOneClassNu = 0.1
+
+// OneClass is a writer-dependent one-class classifier, an SVDD trained on
+// flattened feature vectors of enrollment samples. Vector components are
+// model features standardised with their location and scale at training
+// time, so the classifier does not change when the template adapts.
+type OneClass struct {
+	Features []OneClassFeature `json:"features"`
+	classifier.SVDD
+}
+
+// OneClassFeature is a feature of OneClass vectors, addressed like
+// samples.SampleGrid.At, with -1 for the unused index.
+type OneClassFeature struct {
+	Area     AreaType             `json:"area"`
+	Row      int                  `json:"row"`
+	Col      int                  `json:"col"`
+	Type     features.FeatureType `json:"feature"`
+	Location float64              `json:"location"`
+	Scale    float64              `json:"scale"`
+}
+
+func (f OneClassFeature) areaFeature() areaFeature {
+	return areaFeature{row: f.Row, col: f.Col, ftrType: f.Type}
+}
+
+// TrainOneClass trains OneClass on enrollment samples of model, see
+// enrollmentVectors. Nu is the upper bound of the fraction of enrollment
+// samples left outside.
+func TrainOneClass(model *Model, nu float64) (*OneClass, error) {
+	fs, x, err := model.enrollmentVectors()
+	if err != nil {
+		return nil, err
+	}
+	svdd, err := classifier.TrainSVDD(x, nu, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &OneClass{Features: fs, SVDD: *svdd}, nil
+}
+
+// enrollmentVectors returns flattened feature vectors of enrollment samples
+// of model, read from observations of its features, see
+// features.Feature.Observations, and standardised with feature location and
+// scale. Features with zero scale are left out.
+func (model *Model) enrollmentVectors() ([]OneClassFeature, [][]float64, error) {
+	var areas []AreaType
+	for area := range model.config.Areas {
+		areas = append(areas, area)
+	}
+	sort.Slice(areas, func(i, j int) bool { return areas[i] < areas[j] })
+
+	var fs []OneClassFeature
+	var observations [][]float64
+	for _, area := range areas {
+		for _, af := range model.areaFeatures(area) {
+			if af.ftr.Scale() == 0 {
+				continue
+			}
+			obs := af.ftr.Observations()
+			if len(observations) > 0 && len(obs) != len(observations[0]) {
+				return nil, nil, fmt.Errorf("features have %d and %d observations",
+					len(observations[0]), len(obs))
+			}
+			observations = append(observations, obs)
+			fs = append(fs, OneClassFeature{
+				Area:     area,
+				Row:      af.row,
+				Col:      af.col,
+				Type:     af.ftrType,
+				Location: af.ftr.Location(),
+				Scale:    af.ftr.Scale(),
+			})
+		}
+	}
+	if len(fs) == 0 {
+		return nil, nil, fmt.Errorf("no varying features in enrollment samples")
+	}
+
+	x := make([][]float64, len(observations[0]))
+	for i := range x {
+		x[i] = make([]float64, len(fs))
+		for j, f := range fs {
+			x[i][j] = (observations[j][i] - f.Location) / f.Scale
+		}
+	}
+	return fs, x, nil
+}
+
+// Score returns distance of sample features extracted in pattern, see
+// Model.Score, from the center of the one-class model relative to its radius.
+// Samples scoring below 1 are accepted by the classifier.
+func (oc *OneClass) Score(pattern *Model) (float64, error) {
+	x := make([]float64, len(oc.Features))
+	for i, f := range oc.Features {
+		ftr := pattern.feature(f.Area, f.areaFeature())
+		if ftr == nil {
+			return 0, fmt.Errorf("sample has no %s %s (%d,%d)", f.Area, f.Type, f.Row, f.Col)
+		}
+		x[i] = (ftr.Value() - f.Location) / f.Scale
+	}
+	return oc.SVDD.Score(x), nil
+}
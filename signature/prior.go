@@ -0,0 +1,110 @@
+package signature
+
+import (
+	"fmt"
+	"github.com/radekwlsk/handauth/signature/features"
+	"io"
+)
+
+// VariancePrior is a population prior of feature variances, one per area and
+// feature type, learned from templates of many users with LearnVariancePrior.
+// Strength is the number of pseudo-samples the prior variance counts for. In
+// JSON it reads:
+//
+//	{
+//	  "users": 100, "strength": 2,
+//	  "variances": {
+//	    "BasicArea": {"LengthFeature": 2211.9, "AspectFeature": 0.012},
+//	    "GridArea": {"LengthFeature": 4.7, "HOGFeature": 0.08}
+//	  }
+//	}
+type VariancePrior struct {
+	Users     int                                           `json:"users"`
+	Strength  float64                                       `json:"strength"`
+	Variances map[AreaType]map[features.FeatureType]float64 `json:"variances"`
+}
+
+// LearnVariancePrior sets prior variance of every area and feature type to
+// the mean variance of the feature over all cells of models where it varied
+// between enrollment samples.
+func LearnVariancePrior(models []*Model, strength float64) (*VariancePrior, error) {
+	if !(strength > 0) {
+		return nil, fmt.Errorf("prior strength has to be positive, got %f", strength)
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no models to learn prior from")
+	}
+	sums := make(map[AreaType]map[features.FeatureType]float64)
+	counts := make(map[AreaType]map[features.FeatureType]int)
+	for _, model := range models {
+		for area := range model.config.Areas {
+			if sums[area] == nil {
+				sums[area] = make(map[features.FeatureType]float64)
+				counts[area] = make(map[features.FeatureType]int)
+			}
+			for _, af := range model.areaFeatures(area) {
+				if v := af.ftr.Var(); v > 0 {
+					sums[area][af.ftrType] += v
+					counts[area][af.ftrType]++
+				}
+			}
+		}
+	}
+	prior := &VariancePrior{
+		Users:     len(models),
+		Strength:  strength,
+		Variances: make(map[AreaType]map[features.FeatureType]float64),
+	}
+	for area, ftrSums := range sums {
+		prior.Variances[area] = make(map[features.FeatureType]float64)
+		for ftrType, sum := range ftrSums {
+			prior.Variances[area][ftrType] = sum / float64(counts[area][ftrType])
+		}
+	}
+	return prior, nil
+}
+
+func (prior *VariancePrior) validate() error {
+	if prior.Strength < 0 {
+		return fmt.Errorf("negative prior strength")
+	}
+	return nil
+}
+
+func LoadVariancePrior(r io.Reader) (*VariancePrior, error) {
+	prior := new(VariancePrior)
+	if err := loadJSON(r, prior); err != nil {
+		return nil, err
+	}
+	return prior, nil
+}
+
+func ReadVariancePrior(filename string) (*VariancePrior, error) {
+	prior := new(VariancePrior)
+	if err := readJSON(filename, prior); err != nil {
+		return nil, err
+	}
+	return prior, nil
+}
+
+func (prior *VariancePrior) Write(filename string) error {
+	return writeJSON(filename, prior)
+}
+
+// SetVariancePrior sets prior of every model feature, see
+// features.Feature.SetPrior. Features without prior variance and all
+// features if prior is nil are left without prior. The prior is saved with
+// the template.
+func (model *Model) SetVariancePrior(prior *VariancePrior) {
+	for area := range model.config.Areas {
+		for _, af := range model.areaFeatures(area) {
+			var variance, strength float64
+			if prior != nil {
+				if v, ok := prior.Variances[area][af.ftrType]; ok {
+					variance, strength = v, prior.Strength
+				}
+			}
+			af.ftr.SetPrior(variance, strength)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package signature
+
+import (
+	"github.com/radekwlsk/handauth/samples"
+)
+
+// registered returns sample registered to the reference image of the model
+// and function closing it. Sample itself is returned if the model does not
+// register samples or has no reference image yet.
+func (model *Model) registered(sample *samples.Sample) (*samples.Sample, func()) {
+	if !model.config.Register || model.reference == nil {
+		return sample, func() {}
+	}
+	registered := sample.Register(model.reference)
+	return registered, registered.Close
+}
+
+// addReference adds registered enrollment sample to the reference image of
+// the model as n-th sample, starting the reference with the first one.
+func (model *Model) addReference(sample *samples.Sample, n int) error {
+	if !model.config.Register {
+		return nil
+	}
+	if model.reference == nil {
+		reference, err := samples.NewReference(sample.Width(), sample.Height())
+		if err != nil {
+			return err
+		}
+		model.reference = reference
+	}
+	return model.reference.Add(sample, n)
+}
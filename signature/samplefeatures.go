@@ -0,0 +1,101 @@
+package signature
+
+import (
+	"github.com/radekwlsk/handauth/samples"
+)
+
+// SampleFeatures are features of a questioned sample extracted once, to be
+// scored against any number of templates with Model.ScoreFeatures or
+// UserModel.ScoreFeatures instead of extracting the sample again for each of
+// them. Features are extracted at the full grid of the configuration given
+// to ExtractFeatures and once more for every other grid scored against.
+// Templates registering samples are scored against the sample itself, which
+// has to be kept open while features are scored. SampleFeatures are not safe
+// for concurrent use.
+type SampleFeatures struct {
+	set featureSet
+}
+
+// ExtractFeatures extracts features of sample at the full grid of config.
+func ExtractFeatures(sample *samples.Sample, config ModelConfig) (*SampleFeatures, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	sf := &SampleFeatures{set: featureSet{sample: sample}}
+	if !config.Register {
+		sf.set.get(config)
+	}
+	return sf, nil
+}
+
+// ScoreFeatures scores sample features against the model like Score. Returned
+// pattern is shared by all templates of the same grid.
+func (model *Model) ScoreFeatures(sf *SampleFeatures) (Score, *Model) {
+	return sf.set.score(model)
+}
+
+// ScoreFeatures scores sample features against the user template or its
+// best matching style like ScoreStyle.
+func (um *UserModel) ScoreFeatures(sf *SampleFeatures) (Score, *Model, int) {
+	return um.scoreStyle(sf.set.score)
+}
+
+// sampleFeatures are features of a questioned sample extracted once at every
+// cell of the grid of a model configuration, so the sample can be scored
+// against any number of templates with the same grid without extracting it
+// again for each of them. Templates have cells filtered out, features
+// extracted in all of them cover every template.
+type sampleFeatures struct {
+	pattern *Model
+}
+
+// covers tells if features were extracted at the grid of config with every
+// feature of its areas, so templates of config can be scored against them.
+// Features never cover configs registering samples, as samples are
+// registered to the reference image of every template.
+func (sf *sampleFeatures) covers(config ModelConfig) bool {
+	extracted := sf.pattern.config
+	if config.Register {
+		return false
+	}
+	if extracted.Rows != config.Rows || extracted.Cols != config.Cols {
+		return false
+	}
+	for area, areaConfig := range config.Areas {
+		for _, t := range areaConfig.Features {
+			if !extracted.HasFeature(area, t) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// featureSet extracts features of a sample lazily, once for every grid of
+// templates the sample is scored against.
+type featureSet struct {
+	sample    *samples.Sample
+	extracted []*sampleFeatures
+}
+
+func (fs *featureSet) get(config ModelConfig) *sampleFeatures {
+	for _, sf := range fs.extracted {
+		if sf.covers(config) {
+			return sf
+		}
+	}
+	sf := &sampleFeatures{pattern: newModel(config, nil)}
+	sf.pattern.extractPattern(fs.sample)
+	fs.extracted = append(fs.extracted, sf)
+	return sf
+}
+
+// score scores the sample against model like Model.Score. Returned pattern is
+// shared by all templates of the same grid.
+func (fs *featureSet) score(model *Model) (Score, *Model) {
+	if model.config.Register {
+		return model.Score(fs.sample)
+	}
+	sf := fs.get(model.config)
+	return model.score(sf.pattern), sf.pattern
+}
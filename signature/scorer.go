@@ -0,0 +1,68 @@
+package signature
+
+import "fmt"
+
+// Scorer selects how sample features are scored against a template within
+// an area.
+type Scorer int
+
+const (
+	// StdScorer averages absolute z-scores of features, see scoreBasic.
+	StdScorer Scorer = iota
+	// MahalanobisScorer scores the whole area feature vector with Mahalanobis
+	// distance under a shrinkage covariance estimate, see scoreMahalanobis.
+	MahalanobisScorer
+)
+
+func (s Scorer) String() string {
+	switch s {
+	case StdScorer:
+		return "StdScorer"
+	case MahalanobisScorer:
+		return "MahalanobisScorer"
+	default:
+		return fmt.Sprintf("Scorer(%d)", int(s))
+	}
+}
+
+func (s Scorer) MarshalText() ([]byte, error) {
+	if s < StdScorer || s > MahalanobisScorer {
+		return nil, fmt.Errorf("unknown scorer %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *Scorer) UnmarshalText(text []byte) error {
+	for t := StdScorer; t <= MahalanobisScorer; t++ {
+		if t.String() == string(text) {
+			*s = t
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown scorer %q", string(text))
+}
+
+func (model *Model) getScoreFunc(area AreaType) (func(ftr1, ftr2 *Model) float64, bool) {
+	if model.config.Scorer == MahalanobisScorer {
+		switch area {
+		case BasicAreaType, GridAreaType, RowAreaType, ColAreaType:
+			return func(t, s *Model) float64 {
+				return scoreMahalanobis(t, s, area)
+			}, true
+		default:
+			return nil, false
+		}
+	}
+	switch area {
+	case BasicAreaType:
+		return scoreBasic, true
+	case GridAreaType:
+		return scoreGrid, true
+	case RowAreaType:
+		return scoreRow, true
+	case ColAreaType:
+		return scoreCol, true
+	default:
+		return nil, false
+	}
+}
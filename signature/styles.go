@@ -0,0 +1,206 @@
+package signature
+
+import (
+	"github.com/radekwlsk/handauth/samples"
+	"math"
+)
+
+// MinStyleSilhouette is the mean silhouette enrollment samples clustered
+// into styles need to reach to be split, so samples of signers with a single
+// style are not split arbitrarily.
+const MinStyleSilhouette = 0.5
+
+// minStyleSamples is the least number of samples of a style, as a template
+// of a single sample has no variance.
+const minStyleSamples = 2
+
+// Styles clusters enrollment samples of model into at most k signature
+// styles with k-medoids on their standardised feature vectors, see
+// enrollmentVectors, and returns indexes of samples, in the order they were
+// extracted, of every style. Number of styles with the highest mean
+// silhouette is chosen, a single style if none reaches MinStyleSilhouette or
+// styles would have fewer than 2 samples.
+func (model *Model) Styles(k int) ([][]int, error) {
+	_, x, err := model.enrollmentVectors()
+	if err != nil {
+		return nil, err
+	}
+	n := len(x)
+	all := make([]int, n)
+	for i := range all {
+		all[i] = i
+	}
+	best, bestSilhouette := [][]int{all}, MinStyleSilhouette
+	if n < 2*minStyleSamples {
+		return best, nil
+	}
+
+	dist := make([][]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			var d2 float64
+			for f := range x[i] {
+				d := x[i][f] - x[j][f]
+				d2 += d * d
+			}
+			dist[i][j] = math.Sqrt(d2)
+			dist[j][i] = dist[i][j]
+		}
+	}
+
+	for c := 2; c <= k && c*minStyleSamples <= n; c++ {
+		clusters := kMedoids(dist, c)
+		small := false
+		for _, cluster := range clusters {
+			small = small || len(cluster) < minStyleSamples
+		}
+		if small {
+			continue
+		}
+		if s := silhouette(dist, clusters); s > bestSilhouette {
+			best, bestSilhouette = clusters, s
+		}
+	}
+	return best, nil
+}
+
+// kMedoids clusters points of distance matrix dist into k clusters with PAM:
+// greedy build of medoids followed by swaps of medoids with other points
+// while the total distance to medoids decreases.
+func kMedoids(dist [][]float64, k int) [][]int {
+	n := len(dist)
+	cost := func(medoids []int) float64 {
+		var total float64
+		for i := 0; i < n; i++ {
+			d := math.Inf(1)
+			for _, m := range medoids {
+				d = math.Min(d, dist[i][m])
+			}
+			total += d
+		}
+		return total
+	}
+	isMedoid := func(medoids []int, i int) bool {
+		for _, m := range medoids {
+			if m == i {
+				return true
+			}
+		}
+		return false
+	}
+
+	var medoids []int
+	for len(medoids) < k {
+		next, nextCost := -1, math.Inf(1)
+		for i := 0; i < n; i++ {
+			if isMedoid(medoids, i) {
+				continue
+			}
+			if c := cost(append(medoids, i)); c < nextCost {
+				next, nextCost = i, c
+			}
+		}
+		medoids = append(medoids, next)
+	}
+
+	current := cost(medoids)
+	for improved := true; improved; {
+		improved = false
+		for mi := range medoids {
+			for i := 0; i < n; i++ {
+				if isMedoid(medoids, i) {
+					continue
+				}
+				old := medoids[mi]
+				medoids[mi] = i
+				if c := cost(medoids); c < current {
+					current, improved = c, true
+				} else {
+					medoids[mi] = old
+				}
+			}
+		}
+	}
+
+	clusters := make([][]int, k)
+	for i := 0; i < n; i++ {
+		nearest := 0
+		for mi, m := range medoids {
+			if dist[i][m] < dist[i][medoids[nearest]] {
+				nearest = mi
+			}
+		}
+		clusters[nearest] = append(clusters[nearest], i)
+	}
+	return clusters
+}
+
+// silhouette returns mean silhouette of points of clusters.
+func silhouette(dist [][]float64, clusters [][]int) float64 {
+	var total float64
+	var count int
+	for ci, cluster := range clusters {
+		for _, i := range cluster {
+			count++
+			if len(cluster) < 2 {
+				continue
+			}
+			var a float64
+			for _, j := range cluster {
+				a += dist[i][j]
+			}
+			a /= float64(len(cluster) - 1)
+			b := math.Inf(1)
+			for cj, other := range clusters {
+				if cj == ci || len(other) == 0 {
+					continue
+				}
+				var d float64
+				for _, j := range other {
+					d += dist[i][j]
+				}
+				b = math.Min(b, d/float64(len(other)))
+			}
+			if m := math.Max(a, b); m > 0 {
+				total += (b - a) / m
+			}
+		}
+	}
+	return total / float64(count)
+}
+
+// ScoreStyle scores sample against the style template of the user model
+// that matches it best, the one with the lowest weighted mean area score,
+// and returns the score normalised like Score, pattern of the sample and
+// index of the style. Users without styles are scored against Model and
+// style is -1. Features of the sample are extracted once for all styles.
+func (um *UserModel) ScoreStyle(sample *samples.Sample) (Score, *Model, int) {
+	if len(um.Styles) == 0 {
+		return um.scoreStyle(func(model *Model) (Score, *Model) {
+			return model.Score(sample)
+		})
+	}
+	fs := &featureSet{sample: sample}
+	return um.scoreStyle(fs.score)
+}
+
+func (um *UserModel) scoreStyle(score func(model *Model) (Score, *Model)) (Score, *Model, int) {
+	if len(um.Styles) == 0 {
+		s, pattern := score(um.Model)
+		return um.Normalise(s), pattern, -1
+	}
+	var best Score
+	var bestPattern *Model
+	style, bestValue := -1, math.Inf(1)
+	for i, model := range um.Styles {
+		s, pattern := score(model)
+		value, _ := WeightedSumFusion{}.Fuse(s, model.config.Weights())
+		if style < 0 || value < bestValue {
+			best, bestPattern, style, bestValue = s, pattern, i, value
+		}
+	}
+	return um.Normalise(best), bestPattern, style
+}
@@ -0,0 +1,68 @@
+package signature
+
+import (
+	"github.com/radekwlsk/handauth/signature/features"
+	"sort"
+)
+
+// areaFeature is a feature of a single cell of an area. Cells are addressed
+// like in samples.SampleGrid.At, with -1 for the unused index.
+type areaFeature struct {
+	row, col int
+	ftrType  features.FeatureType
+	ftr      *features.Feature
+}
+
+// areaFeatures returns all features of area ordered by cell and feature type,
+// so the order is the same for every model with the same cells.
+func (model *Model) areaFeatures(area AreaType) []areaFeature {
+	var afs []areaFeature
+	add := func(row, col int, ftrMap features.FeatureMap) {
+		for ftrType, ftr := range ftrMap {
+			afs = append(afs, areaFeature{row: row, col: col, ftrType: ftrType, ftr: ftr})
+		}
+	}
+	switch area {
+	case BasicAreaType:
+		add(-1, -1, model.basic)
+	case GridAreaType:
+		for rc, ftrMap := range model.grid {
+			add(rc[0], rc[1], ftrMap)
+		}
+	case RowAreaType:
+		for r, ftrMap := range model.row {
+			add(r, -1, ftrMap)
+		}
+	case ColAreaType:
+		for c, ftrMap := range model.col {
+			add(-1, c, ftrMap)
+		}
+	}
+	sort.Slice(afs, func(i, j int) bool {
+		if afs[i].row != afs[j].row {
+			return afs[i].row < afs[j].row
+		}
+		if afs[i].col != afs[j].col {
+			return afs[i].col < afs[j].col
+		}
+		return afs[i].ftrType < afs[j].ftrType
+	})
+	return afs
+}
+
+// feature returns feature of model at the same cell of area as af, nil if
+// model has no such feature.
+func (model *Model) feature(area AreaType, af areaFeature) *features.Feature {
+	var ftrMap features.FeatureMap
+	switch area {
+	case BasicAreaType:
+		ftrMap = model.basic
+	case GridAreaType:
+		ftrMap = model.grid[[2]int{af.row, af.col}]
+	case RowAreaType:
+		ftrMap = model.row[af.row]
+	case ColAreaType:
+		ftrMap = model.col[af.col]
+	}
+	return ftrMap[af.ftrType]
+}
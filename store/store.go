@@ -0,0 +1,145 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/radekwlsk/handauth/signature"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	filePrefix = "user-"
+	fileSuffix = ".json"
+)
+
+var ErrNotFound = errors.New("user not enrolled")
+
+// Store keeps enrolled user templates on local disk, one JSON file per user
+// in a single directory. Writes are atomic (temporary file and rename) and
+// all operations of a Store are serialised by a read-write lock, so a single
+// Store can be shared by concurrent enroll and verify goroutines.
+type Store struct {
+	dir   string
+	mutex sync.RWMutex
+}
+
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) path(id uint16) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%05d%s", filePrefix, id, fileSuffix))
+}
+
+func (s *Store) Put(um *signature.UserModel) error {
+	if um.Model == nil {
+		return fmt.Errorf("user %d has no enrolled model", um.Id)
+	}
+	b, err := json.Marshal(um)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.write(um.Id, b)
+}
+
+func (s *Store) write(id uint16, b []byte) error {
+	return signature.WriteFileAtomic(s.path(id), b)
+}
+
+func (s *Store) Get(id uint16) (*signature.UserModel, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.read(id)
+}
+
+func (s *Store) read(id uint16) (*signature.UserModel, error) {
+	b, err := ioutil.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("user %d: %w", id, ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	um := new(signature.UserModel)
+	if err := json.Unmarshal(b, um); err != nil {
+		return nil, fmt.Errorf("user %d: %v", id, err)
+	}
+	return um, nil
+}
+
+// Update applies fn to the template of user id and saves the result, holding
+// the store lock for the whole read-modify-write. Template is not saved if fn
+// returns an error.
+func (s *Store) Update(id uint16, fn func(um *signature.UserModel) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	um, err := s.read(id)
+	if err != nil {
+		return err
+	}
+	if err := fn(um); err != nil {
+		return err
+	}
+	if um.Id != id {
+		return fmt.Errorf("user %d: cannot change id to %d", id, um.Id)
+	}
+	b, err := json.Marshal(um)
+	if err != nil {
+		return err
+	}
+	return s.write(id, b)
+}
+
+func (s *Store) Delete(id uint16) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("user %d: %w", id, ErrNotFound)
+	}
+	return err
+}
+
+func (s *Store) List() ([]uint16, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint16
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix), 10, 16)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint16(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
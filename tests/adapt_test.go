@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/features"
+	"github.com/radekwlsk/handauth/store"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+func adaptModel(t *testing.T) *signature.Model {
+	return basicTemplate(t, 4,
+		features.State{Type: features.LengthFeatureType, Mean: 40, Variance: 4},
+		features.State{Type: features.AspectFeatureType, Mean: 2, Variance: 0.01},
+	)
+}
+
+// adaptInk returns n ink pixels of 40x20 sample in rows from the 10th on,
+// length feature of n.
+func adaptInk(n int) func(r, c int) bool {
+	return func(r, c int) bool { return r >= 10 && (r-10)*40+c < n }
+}
+
+func TestAdapt(t *testing.T) {
+	model := adaptModel(t)
+	for _, policy := range []signature.AdaptPolicy{{Threshold: 0, Window: 10}, {Threshold: 1, Window: 1}} {
+		if _, err := model.Adapt(inkSample(40, 20, adaptInk(40)), 0, policy); err == nil {
+			t.Errorf("adapted with invalid policy %+v", policy)
+		}
+	}
+
+	for _, value := range []float64{1, math.NaN()} {
+		ok, err := model.Adapt(inkSample(40, 20, adaptInk(100)), value, signature.AdaptPolicy{Threshold: 1, Window: 10})
+		if err != nil || ok {
+			t.Fatalf("adapted sample of value %f, %v", value, err)
+		}
+	}
+	if v := model.Basic()[features.LengthFeatureType].Value(); v != 40 || model.SamplesCount() != 4 {
+		t.Fatalf("rejected sample changed template to %f of %d samples", v, model.SamplesCount())
+	}
+
+	for _, c := range []struct {
+		window  int
+		samples int
+		mean    float64
+	}{
+		{10, 5, 40 + 2.0/5},
+		{4, 4, 40 + 2.0/4},
+	} {
+		model := adaptModel(t)
+		ok, err := model.Adapt(inkSample(40, 20, adaptInk(42)), 0.5, signature.AdaptPolicy{Threshold: 1, Window: c.window})
+		if err != nil || !ok {
+			t.Fatalf("window %d: sample not adapted, %v", c.window, err)
+		}
+		v := model.Basic()[features.LengthFeatureType].Value()
+		if model.SamplesCount() != c.samples || math.Abs(v-c.mean) > 1e-9 {
+			t.Errorf("window %d: adapted to %f of %d samples, want %f of %d", c.window, v,
+				model.SamplesCount(), c.mean, c.samples)
+		}
+	}
+}
+
+func TestAdaptRejectedSampleKeepsStoredTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "handauth-adapt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	templates, err := store.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// consistent user, typical genuine score of 0.1
+	um := &signature.UserModel{
+		Id:    User,
+		Model: adaptModel(t),
+		Norm:  &signature.ScoreNorm{Scale: map[signature.AreaType]float64{signature.BasicAreaType: 0.1}},
+	}
+	if err := templates.Put(um); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := json.Marshal(um)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// raw score 0.5 passes the threshold, normalised 5 is rejected
+	const threshold = 1.0
+	sample := inkSample(40, 20, adaptInk(42))
+	raw, _ := um.Model.Score(sample)
+	value, err := signature.MaxFusion{}.Fuse(um.Normalise(raw), um.Model.Config().Weights())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw[signature.BasicAreaType] >= threshold || signature.Decide(value, threshold, threshold) != signature.Reject {
+		t.Fatalf("raw score %v, value %f", raw, value)
+	}
+	ok, err := um.Model.Adapt(sample, value, signature.AdaptPolicy{Threshold: threshold, Window: 10})
+	if err != nil || ok {
+		t.Fatalf("adapted rejected sample, %v", err)
+	}
+	loaded, err := templates.Get(um.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*signature.UserModel{um, loaded} {
+		if b, err := json.Marshal(m); err != nil || string(b) != string(stored) {
+			t.Fatalf("rejected sample changed template: %s", b)
+		}
+	}
+}
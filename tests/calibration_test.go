@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/radekwlsk/handauth/signature"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCalibration(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var genuine, forgery []float64
+	for i := 0; i < 1000; i++ {
+		genuine = append(genuine, 1+rng.NormFloat64()*0.3)
+	}
+	for i := 0; i < 3000; i++ {
+		forgery = append(forgery, 2+rng.NormFloat64()*0.3)
+	}
+	for _, method := range []signature.CalibrationMethod{
+		signature.LogisticCalibration,
+		signature.IsotonicCalibration,
+	} {
+		c, err := signature.TrainCalibration(method, genuine, forgery, 1e-6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := signature.LoadCalibration(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// equal variances make LLR 0 halfway between the means
+		if llr := loaded.LLR(1.5); math.Abs(llr) > 0.5 {
+			t.Errorf("%s LLR at 1.5 is %f, want about 0", method, llr)
+		}
+		for s := 0.0; s < 3; s += 0.05 {
+			if loaded.LLR(s+0.05) > loaded.LLR(s)+1e-9 {
+				t.Errorf("%s LLR increases at %f", method, s)
+			}
+		}
+		if llr, decision := loaded.Verify(1); decision != signature.Accept || llr <= 0 {
+			t.Errorf("%s verifies genuine score as %s with LLR %f", method, decision, llr)
+		}
+		if llr, decision := loaded.Verify(2); decision != signature.Reject || llr >= 0 {
+			t.Errorf("%s verifies forgery score as %s with LLR %f", method, decision, llr)
+		}
+		if _, decision := loaded.Verify(math.NaN()); decision != signature.Reject {
+			t.Errorf("%s accepts NaN score", method)
+		}
+	}
+}
+
+func TestCalibrationFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "handauth-calibration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "calibration.json")
+	for _, threshold := range []float64{1, 2} {
+		c := &signature.Calibration{Method: signature.LogisticCalibration, Slope: -1, Threshold: threshold}
+		if err := c.Write(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := signature.ReadCalibration(filename)
+	if err != nil || c.Threshold != 2 {
+		t.Fatalf("read %+v, %v", c, err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("%d files left in %s, %v", len(files), dir, err)
+	}
+
+	broken := &signature.Calibration{Method: signature.IsotonicCalibration}
+	if err := broken.Write(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signature.ReadCalibration(filename); err == nil {
+		t.Error("read isotonic calibration without scores")
+	}
+}
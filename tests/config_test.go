@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/features"
+	"strings"
+	"testing"
+)
+
+const testModelConfig = `{
+  "rows": 4, "cols": 8,
+  "areas": {
+    "BasicArea": {"features": ["LengthFeature", "AspectFeature"], "weight": 1.0},
+    "GridArea": {"features": ["LengthFeature"], "weight": 2.0}
+  },
+  "area_filter": {"enabled": true, "field_threshold": 0.03, "rowcol_threshold": 0.02},
+  "std_filter": {"enabled": false},
+  "scorer": "MahalanobisScorer", "deskew": true
+}`
+
+func TestModelConfigStoredInTemplate(t *testing.T) {
+	config, err := signature.LoadModelConfig(strings.NewReader(testModelConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := signature.NewModelFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint64(2 + 4*8); model.FeaturesCount() != want {
+		t.Fatalf("got %d features, want %d", model.FeaturesCount(), want)
+	}
+	b, err := json.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := new(signature.Model)
+	if err := json.Unmarshal(b, loaded); err != nil {
+		t.Fatal(err)
+	}
+	c := loaded.Config()
+	if c.Rows != 4 || c.Cols != 8 || c.Has(signature.RowAreaType) ||
+		!c.HasFeature(signature.BasicAreaType, features.AspectFeatureType) ||
+		c.Weights()[signature.GridAreaType] != 2.0 || c.Scorer != signature.MahalanobisScorer ||
+		!c.Deskew {
+		t.Fatalf("loaded config %+v differs from %+v", c, config)
+	}
+}
+
+func TestModelConfigInvalid(t *testing.T) {
+	for _, s := range []string{
+		`{"rows": 4, "cols": 8, "areas": {}}`,
+		`{"rows": 0, "cols": 8, "areas": {"GridArea": {"features": ["LengthFeature"]}}}`,
+		`{"areas": {"BasicArea": {"features": []}}}`,
+		`{"areas": {"BasicArea": {"features": ["NoSuchFeature"]}}}`,
+		`{"areas": {"BasicArea": {"features": ["LengthFeature"], "weight": -1}}}`,
+		`{"areas": {"BasicArea": {"features": ["LengthFeature"], "weight": 0}}}`,
+		`{"areas": {"BasicArea": {"features": ["LengthFeature"]}}, "area_filter": {"enabled": true}}`,
+		`{"areas": {"BasicArea": {"features": ["LengthFeature"]}}, "std_filter": {"enabled": true}}`,
+		`{"areas": {"BasicArea": {"features": ["LengthFeature"]}}, "scorer": "NoSuchScorer"}`,
+		`{"areas": {"BasicArea": {"features": ["LengthFeature"]}}, "shrinkage": 1.5}`,
+	} {
+		if _, err := signature.LoadModelConfig(strings.NewReader(s)); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
+
+func TestModelConfigDefaultWeight(t *testing.T) {
+	config, err := signature.LoadModelConfig(strings.NewReader(
+		`{"areas": {"BasicArea": {"features": ["LengthFeature"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := config.Weights()[signature.BasicAreaType]; w != 1.0 {
+		t.Fatalf("missing weight loaded as %f, want 1", w)
+	}
+}
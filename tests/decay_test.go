@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature/features"
+	"math"
+	"testing"
+	"time"
+)
+
+// lengthSample returns sample with length feature of n.
+func lengthSample(n int) *samples.Sample {
+	return inkSample(40, 20, func(r, c int) bool { return r*40+c < n })
+}
+
+func TestUpdateAt(t *testing.T) {
+	ftr := features.NewLengthFeature()
+	ftr.SetHalfLife(time.Hour)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// the first sample is weighted 1/2 after an hour, the late one on arrival
+	for _, c := range []struct {
+		value  int
+		at     time.Time
+		mean   float64
+		std    float64
+		weight float64
+	}{
+		{10, start, 10, 0, 1},
+		{20, start.Add(time.Hour), 10 + 10/1.5, math.Sqrt(10 * (20 - 10 - 10/1.5) / 1.5), 1.5},
+		{10, start, 15, 5, 2},
+	} {
+		ftr.UpdateAt(lengthSample(c.value), c.at)
+		if math.Abs(ftr.Value()-c.mean) > 1e-9 || math.Abs(ftr.Std()-c.std) > 1e-9 ||
+			math.Abs(ftr.Weight()-c.weight) > 1e-9 {
+			t.Fatalf("after %d at %v: mean %f, std %f, weight %f, want %f, %f, %f", c.value, c.at,
+				ftr.Value(), ftr.Std(), ftr.Weight(), c.mean, c.std, c.weight)
+		}
+	}
+	if !ftr.Updated().Equal(start.Add(time.Hour)) {
+		t.Errorf("updated at %v, want time of the newest sample", ftr.Updated())
+	}
+}
@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"github.com/radekwlsk/handauth/signature"
+	"math"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	weights := signature.AreaThresholdWeights{signature.GridAreaType: 2}
+	for _, c := range []struct {
+		score signature.Score
+		want  signature.Decision
+	}{
+		{signature.Score{signature.BasicAreaType: 0.5, signature.GridAreaType: 0.4}, signature.Accept},
+		{signature.Score{signature.BasicAreaType: 0.5, signature.GridAreaType: 0.6}, signature.Review},
+		{signature.Score{signature.BasicAreaType: 1.5, signature.GridAreaType: 0.4}, signature.Reject},
+		{signature.Score{signature.BasicAreaType: 0.5, signature.RowAreaType: math.NaN()}, signature.Accept},
+	} {
+		if d := c.score.Decide(1, 1.5, weights); d != c.want {
+			t.Errorf("%v decided %s, want %s", c.score, d, c.want)
+		}
+	}
+	if d := signature.Decide(math.NaN(), 1, 2); d != signature.Reject {
+		t.Errorf("NaN decided %s, want Reject", d)
+	}
+	var d signature.Decision
+	if err := d.UnmarshalText([]byte("Review")); err != nil || d != signature.Review {
+		t.Errorf("unmarshalled %s, %v", d, err)
+	}
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"github.com/radekwlsk/handauth/cmd"
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature"
+	"math"
+	"testing"
+)
+
+// slopeSample returns a line through the center of 200x100 sample rising by
+// slope per column.
+func slopeSample(slope float64) *samples.Sample {
+	return inkSample(200, 100, func(r, c int) bool {
+		return c >= 40 && c < 160 && math.Abs(float64(r)-50-slope*float64(c-100)) < 1
+	})
+}
+
+func TestDeskew(t *testing.T) {
+	angle := math.Atan(0.2)
+	sample := slopeSample(0.2)
+	sample.Deskew()
+	if math.Abs(sample.Skew()-angle) > 0.02 {
+		t.Errorf("skew %f, want %f", sample.Skew(), angle)
+	}
+	if p, ok := sample.Pose(); !ok || math.Abs(p.Angle) > 0.02 {
+		t.Errorf("deskewed pose %+v, %v", p, ok)
+	}
+	if sample.Width() <= 200 || sample.Height() <= 100 {
+		t.Errorf("deskewed sample cut to %dx%d", sample.Width(), sample.Height())
+	}
+
+	steep := slopeSample(2)
+	steep.Deskew()
+	if math.Abs(steep.Skew()-math.Atan(2)) > 0.02 || steep.Width() != 200 || steep.Height() != 100 {
+		t.Errorf("steep sample of skew %f rotated to %dx%d", steep.Skew(), steep.Width(), steep.Height())
+	}
+
+	empty := inkSample(200, 100, func(r, c int) bool { return false })
+	empty.Deskew()
+	if empty.Skew() != 0 {
+		t.Errorf("empty sample skew %f", empty.Skew())
+	}
+}
+
+func TestCheckPreprocess(t *testing.T) {
+	modelConfig := sampleConfig(t)
+	modelConfig.Deskew = true
+	model, err := signature.NewModelFromConfig(modelConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &signature.UserModel{Id: User, Model: model}
+	config := cmd.Config{Model: modelConfig, Preprocess: samples.PreprocessConfig{Deskew: true}}
+	if err := cmd.CheckPreprocess(config, template); err != nil {
+		t.Fatal(err)
+	}
+	config.Preprocess.Deskew = false
+	if err := cmd.CheckPreprocess(config, template); err == nil {
+		t.Fatal("deskewed template checked against samples not deskewed")
+	}
+	if _, err := cmd.Identify(config, User, Index, []*signature.UserModel{template}, nil); err == nil {
+		t.Fatal("identified against deskewed template")
+	}
+}
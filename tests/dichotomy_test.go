@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/radekwlsk/handauth/signature"
+	"math/rand"
+	"testing"
+)
+
+func TestDichotomy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	config := oneClassModel(t, 100, nil, nil).Config()
+	dichotomy, err := signature.NewDichotomy(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// writers differ in mean length, samples of a writer vary by a few units
+	writer := func(mean float64) []*signature.Model {
+		var models []*signature.Model
+		for i := 0; i < 5; i++ {
+			models = append(models, oneClassModel(t, mean+rng.NormFloat64()*2, nil, nil))
+		}
+		return models
+	}
+	var same, different [][]float64
+	var writers [][]*signature.Model
+	for w := 0; w < 10; w++ {
+		writers = append(writers, writer(100+float64(w)*20))
+	}
+	for w, models := range writers {
+		for i := range models {
+			for j := i + 1; j < len(models); j++ {
+				same = append(same, dichotomy.Dissimilarity(models[i], models[j]))
+			}
+			for _, other := range writers[w+1:] {
+				different = append(different, dichotomy.Dissimilarity(models[i], other[i]))
+			}
+		}
+	}
+	if err := dichotomy.Train(same, different, 1e-3); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(dichotomy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := signature.LoadDichotomy(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	references := writer(150)
+	if s, err := loaded.Score(writer(150)[0], references); err != nil || s >= 0 {
+		t.Errorf("same writer scores %f, %v", s, err)
+	}
+	if s, err := loaded.Score(writer(250)[0], references); err != nil || s <= 0 {
+		t.Errorf("different writer scores %f, %v", s, err)
+	}
+}
+
+func TestDichotomyExtractDoesNotRegister(t *testing.T) {
+	config := sampleConfig(t)
+	config.Register = true
+	dichotomy, err := signature.NewDichotomy(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sample := strokeSample(rand.New(rand.NewSource(1)), 20)
+	a, b := dichotomy.Extract(sample), dichotomy.Extract(sample)
+	if a.Reference() != nil {
+		t.Fatal("reference kept for a single sample")
+	}
+	for i, d := range dichotomy.Dissimilarity(a, b) {
+		if d != 0 {
+			t.Errorf("%v of sample to itself is %f", dichotomy.Features[i], d)
+		}
+	}
+}
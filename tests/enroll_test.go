@@ -2,13 +2,23 @@ package tests
 
 import (
 	"github.com/radekwlsk/handauth/cmd"
-	"github.com/radekwlsk/handauth/cmd/flags"
 	"github.com/radekwlsk/handauth/signature"
 	"testing"
 )
 
-var ResFlag = int(cmd.GPDSResources)
-var GPDSFlag = 4000
+func gpdsConfig(areas map[signature.AreaType]bool, rows, cols uint16) cmd.Config {
+	config := cmd.Config{
+		Resources: cmd.GPDSResources,
+		GPDSUsers: 4000,
+		Model:     signature.DefaultModelConfig(rows, cols),
+	}
+	for area, enabled := range areas {
+		if !enabled {
+			delete(config.Model.Areas, area)
+		}
+	}
+	return config
+}
 
 func BenchmarkGPDSEnroll2x6(b *testing.B)   { benchmarkGPDSEnroll(nil, 2, 6, b) }
 func BenchmarkGPDSEnroll5x15(b *testing.B)  { benchmarkGPDSEnroll(nil, 5, 15, b) }
@@ -91,35 +101,27 @@ func BenchmarkGPDSVerifyRC(b *testing.B) {
 }
 
 func benchmarkGPDSEnroll(area map[signature.AreaType]bool, rows, cols uint16, b *testing.B) {
-	flags.Resources = &ResFlag
-	flags.GPDSUsers = &GPDSFlag
-	if area != nil {
-		signature.AreaFlags = area
-	}
+	config := gpdsConfig(area, rows, cols)
 
 	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
 		userId := uint16(n%4000 + 1)
-		cmd.EnrollUser(userId, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, rows, cols)
+		_, _ = cmd.EnrollUser(config, userId, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
 	}
 }
 
 func benchmarkGPDSVerify(area map[signature.AreaType]bool, rows, cols uint16, b *testing.B) {
-	flags.Resources = &ResFlag
-	flags.GPDSUsers = &GPDSFlag
-	if area != nil {
-		signature.AreaFlags = area
-	}
+	config := gpdsConfig(area, rows, cols)
 
 	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
 		b.StopTimer()
 		userId := uint16(n%4000 + 1)
 		sampleId := uint8(n%14 + 11)
-		um := cmd.EnrollUser(userId, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, rows, cols)
+		um, _ := cmd.EnrollUser(config, userId, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
 		b.StartTimer()
-		sample, _ := cmd.ReadUserSample(userId, userId, sampleId)
-		sample.Preprocess()
+		sample, _ := cmd.ReadUserSample(config, userId, userId, sampleId)
+		sample.Preprocess(config.Preprocess)
 		score, _ := um.Model.Score(sample.Sample())
 		sample.Close()
 		_, _ = score.Check(1.25, nil)
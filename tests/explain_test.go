@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/features"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExplanation(t *testing.T) {
+	e := &signature.Explanation{Areas: []signature.AreaExplanation{{
+		Area:  signature.GridAreaType,
+		Score: 1.5,
+		Features: []signature.FeatureExplanation{
+			{Row: 1, Col: 2, Type: features.LengthFeatureType, Mean: 10, Std: 0, Value: 12,
+				ZScore: math.Inf(1), Contribution: math.NaN()},
+			{Row: 0, Col: 3, Type: features.AspectFeatureType, Mean: 2, Std: 0.5, Value: 2.5,
+				ZScore: 1, Contribution: 0.5},
+		},
+	}}}
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"z":null`) || !strings.Contains(string(b), `"area":"GridArea"`) {
+		t.Errorf("unexpected JSON %s", b)
+	}
+
+	var buf bytes.Buffer
+	if err := e.Render(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); !strings.Contains(s, "LengthFeature") || strings.Contains(s, "AspectFeature") {
+		t.Errorf("rendered top 1 features as\n%s", s)
+	}
+}
+
+func TestExplainInfiniteZScore(t *testing.T) {
+	model := basicTemplate(t, 4,
+		features.State{Type: features.LengthFeatureType, Mean: 100},
+		features.State{Type: features.AspectFeatureType, Mean: 2, Variance: 0.01},
+	)
+	sample := inkSample(40, 20, func(r, c int) bool { return r == 10 })
+	score, e := model.Explain(sample)
+	if !math.IsInf(score[signature.BasicAreaType], 1) {
+		t.Fatalf("score %v, want infinite", score)
+	}
+	fs := e.Areas[0].Features
+	if len(fs) != 2 || fs[0].Type != features.LengthFeatureType || !math.IsInf(fs[0].Contribution, 1) ||
+		fs[1].Contribution != 0 {
+		t.Fatalf("features %+v, want length of infinite contribution first", fs)
+	}
+}
+
+func TestUserModelExplainStyle(t *testing.T) {
+	length := func(mean float64) *signature.Model {
+		return basicTemplate(t, 4, features.State{Type: features.LengthFeatureType, Mean: mean, Variance: 4})
+	}
+	um := &signature.UserModel{
+		Model:  length(70),
+		Styles: []*signature.Model{length(100), length(42)},
+		Norm:   &signature.ScoreNorm{Scale: map[signature.AreaType]float64{signature.BasicAreaType: 2}},
+	}
+	sample := inkSample(40, 20, func(r, c int) bool { return r == 10 })
+	score, e, style := um.Explain(sample)
+	if style != 1 {
+		t.Fatalf("explained against style %d, want 1", style)
+	}
+	ae := e.Areas[0]
+	if ae.Features[0].Mean != 42 || ae.Score != score[signature.BasicAreaType] {
+		t.Fatalf("explained %+v against score %v", ae, score)
+	}
+	if want := 1.0 / 2; math.Abs(ae.Score-want) > 1e-9 {
+		t.Errorf("normalised score %f, want %f", ae.Score, want)
+	}
+}
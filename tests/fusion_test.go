@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/radekwlsk/handauth/signature"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFusions(t *testing.T) {
+	score := signature.Score{
+		signature.BasicAreaType: 1.0,
+		signature.GridAreaType:  4.0,
+		signature.RowAreaType:   math.NaN(),
+	}
+	weights := signature.AreaThresholdWeights{signature.GridAreaType: 0.5}
+	for _, c := range []struct {
+		name string
+		want float64
+	}{
+		{"max", 2.0},
+		{"min", 1.0},
+		{"sum", 3.0 / 1.5},
+		{"product", math.Pow(4.0, 0.5/1.5)},
+	} {
+		fusion, err := signature.ParseFusion(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fused, err := fusion.Fuse(score, weights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(fused-c.want) > 1e-9 {
+			t.Errorf("%s fusion gives %f, want %f", c.name, fused, c.want)
+		}
+	}
+	if ok, _ := score.Accept(2.5, signature.MaxFusion{}, weights); !ok {
+		t.Error("max fusion rejects score below threshold")
+	}
+
+	zero := signature.Score{signature.BasicAreaType: 0, signature.GridAreaType: 1e6}
+	fused, err := signature.ProductFusion{}.Fuse(zero, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := math.Sqrt(signature.ProductFusionFloor * 1e6); math.Abs(fused-want) > 1e-9 {
+		t.Errorf("product fusion of zero area score gives %f, want %f", fused, want)
+	}
+}
+
+func TestLogisticFusion(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var genuine, forgery []signature.Score
+	for i := 0; i < 200; i++ {
+		genuine = append(genuine, signature.Score{
+			signature.BasicAreaType: 1 + rng.NormFloat64()*0.3,
+			signature.GridAreaType:  1 + rng.NormFloat64()*0.3,
+		})
+		forgery = append(forgery, signature.Score{
+			signature.BasicAreaType: 1 + rng.NormFloat64()*0.3,
+			signature.GridAreaType:  2 + rng.NormFloat64()*0.3,
+		})
+	}
+	fusion, err := signature.TrainLogisticFusion(genuine, forgery, 1e-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// grid area separates the classes, basic does not
+	if !(math.Abs(fusion.Weights[1]) > 5*math.Abs(fusion.Weights[0])) {
+		t.Fatalf("weights %v", fusion.Weights)
+	}
+	errors := 0
+	for _, s := range genuine {
+		if ok, _ := s.Accept(0, fusion, nil); !ok {
+			errors++
+		}
+	}
+	for _, s := range forgery {
+		if ok, _ := s.Accept(0, fusion, nil); ok {
+			errors++
+		}
+	}
+	if errors > 40 {
+		t.Fatalf("%d of 400 samples misclassified", errors)
+	}
+
+	b, err := json.Marshal(fusion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := signature.LoadLogisticFusion(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := fusion.Fuse(genuine[0], nil)
+	l, _ := loaded.Fuse(genuine[0], nil)
+	if a != l {
+		t.Fatalf("loaded fusion gives %f, want %f", l, a)
+	}
+
+	for _, s := range []signature.Score{
+		{signature.BasicAreaType: 1},
+		{signature.BasicAreaType: 1, signature.GridAreaType: math.NaN()},
+	} {
+		if z, err := fusion.Fuse(s, nil); err != nil || !math.IsNaN(z) {
+			t.Errorf("fused incomplete score %v to %f, %v", s, z, err)
+		}
+		if ok, _ := s.Accept(math.Inf(1), fusion, nil); ok {
+			t.Errorf("accepted incomplete score %v", s)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestIdentify(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	config := sampleConfig(t)
+	var templates []*signature.UserModel
+	for id, wave := range []float64{10, 25, 60} {
+		var ss []*samples.Sample
+		for i := 0; i < 4; i++ {
+			ss = append(ss, strokeSample(rng, wave))
+		}
+		templates = append(templates, &signature.UserModel{Id: uint16(id + 1), Model: enroll(t, config, ss)})
+	}
+	// scores of a template without scales are all NaN
+	nan := &signature.UserModel{Id: 4, Model: templates[0].Model, Norm: &signature.ScoreNorm{
+		Scale: map[signature.AreaType]float64{},
+	}}
+	for area := range config.Areas {
+		nan.Norm.Scale[area] = math.NaN()
+	}
+	templates = append([]*signature.UserModel{nan, {Id: 5}}, templates...)
+
+	sample := strokeSample(rng, 60)
+	candidates, err := signature.Identify(sample, templates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 4 {
+		t.Fatalf("%d candidates, want 4 without the one not enrolled", len(candidates))
+	}
+	if c := candidates[3]; c.Id != 4 || !math.IsNaN(c.Value) {
+		t.Errorf("last candidate %+v, want 4 of NaN value", c)
+	}
+	for i, c := range candidates[:3] {
+		want, _ := templates[c.Id+1].Score(sample)
+		if !sameScore(c.Score, want) {
+			t.Errorf("candidate %d scores %v, UserModel.Score gives %v", c.Id, c.Score, want)
+		}
+		if i > 0 && !(candidates[i-1].Value <= c.Value) {
+			t.Errorf("candidate %d of value %f ranked below %f", c.Id, c.Value, candidates[i-1].Value)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	candidates := []signature.Candidate{{Id: 7, Value: 0.5}, {Id: 2, Value: 1}, {Id: 9, Value: math.NaN()}}
+	for id, want := range map[uint16]int{7: 1, 2: 2, 9: 3, 5: 0} {
+		if rank := signature.Rank(candidates, id); rank != want {
+			t.Errorf("rank of %d is %d, want %d", id, rank, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/features"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"testing"
+)
+
+// mahalanobisTemplate returns template of basic area features of states
+// scored with MahalanobisScorer of given shrinkage.
+func mahalanobisTemplate(t *testing.T, shrinkage float64, states ...features.State) *signature.Model {
+	var types []features.FeatureType
+	for _, state := range states {
+		types = append(types, state.Type)
+	}
+	config, err := json.Marshal(types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic, err := json.Marshal(states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := fmt.Sprintf(`{
+  "version": 8, "samples": %d, "areas": ["BasicArea"],
+  "config": {
+    "scorer": "MahalanobisScorer", "shrinkage": %g,
+    "areas": {"BasicArea": {"features": %s, "weight": 1}}
+  },
+  "basic": %s
+}`, len(states[0].Observations), shrinkage, config, basic)
+	model := new(signature.Model)
+	if err := json.Unmarshal([]byte(b), model); err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func TestScoreMahalanobis(t *testing.T) {
+	// length 2 above the mean at the mean aspect, against the correlation
+	sample := inkSample(40, 20, func(r, c int) bool { return r*40+c < 42 })
+	for _, c := range []struct {
+		shrinkage float64
+		want      float64
+	}{
+		// diagonal covariance, root mean square of z-scores
+		{1, math.Sqrt(4 / (8.0 / 3) / 2)},
+		{0.5, 1},
+		// estimated intensity of perfectly correlated features is 1/3
+		{0, math.Sqrt(1.35)},
+	} {
+		// perfectly correlated length and aspect of variances 8/3 and
+		// 0.02/3 and covariance 0.4/3
+		score, _ := mahalanobisTemplate(t, c.shrinkage,
+			features.State{Type: features.LengthFeatureType, Mean: 40, Observations: []float64{38, 40, 42, 40}},
+			features.State{Type: features.AspectFeatureType, Mean: 2, Observations: []float64{1.9, 2, 2.1, 2}},
+		).Score(sample)
+		if s := score[signature.BasicAreaType]; !(math.Abs(s-c.want) <= 1e-9) {
+			t.Errorf("shrinkage %g: score %f, want %f", c.shrinkage, s, c.want)
+		}
+	}
+}
+
+func TestScoreMahalanobisCovariance(t *testing.T) {
+	const shrinkage = 0.3
+	types := []features.FeatureType{
+		features.LengthFeatureType, features.AspectFeatureType, features.MassCenterXFeatureType,
+	}
+	observations := [][]float64{
+		{38, 41, 43, 39, 44},
+		{1.9, 2.05, 2, 1.95, 2.1},
+		{0.45, 0.52, 0.5, 0.48, 0.55},
+	}
+	var states []features.State
+	for i, ftrType := range types {
+		mean, variance := stat.MeanVariance(observations[i], nil)
+		states = append(states, features.State{
+			Type: ftrType, Mean: mean, Variance: variance, Observations: observations[i],
+		})
+	}
+	template := mahalanobisTemplate(t, shrinkage, states...)
+	sample := inkSample(40, 20, func(r, c int) bool { return r*40+c < 42 })
+	score, pattern := template.Score(sample)
+
+	// S* = λ·diag(S) + (1-λ)·S of unbiased sample covariance S, inverted
+	// directly
+	s := mat.NewSymDense(len(types), nil)
+	y := mat.NewVecDense(len(types), nil)
+	for i, ftrType := range types {
+		for j := range types {
+			cov := stat.Covariance(observations[i], observations[j], nil)
+			if i != j {
+				cov *= 1 - shrinkage
+			}
+			s.SetSym(i, j, cov)
+		}
+		value := pattern.Basic()[ftrType].Value()
+		if math.IsNaN(value) {
+			t.Fatalf("sample %s is NaN", ftrType)
+		}
+		y.SetVec(i, value-stat.Mean(observations[i], nil))
+	}
+	var x mat.VecDense
+	if err := x.SolveVec(s, y); err != nil {
+		t.Fatal(err)
+	}
+	want := math.Sqrt(mat.Dot(y, &x) / float64(len(types)))
+	if got := score[signature.BasicAreaType]; !(math.Abs(got-want) <= 1e-9) || !(want > 0) {
+		t.Fatalf("score %f, want %f", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/features"
+	"math"
+	"testing"
+)
+
+func valuesFeature(values []float64) *features.Feature {
+	i := 0
+	return features.NewFeature(func(*samples.Sample) float64 {
+		i++
+		return values[i-1]
+	})
+}
+
+func TestFeatureMerge(t *testing.T) {
+	a, b := []float64{1, 2, 3, 4}, []float64{10, 12}
+	fa, fb, all := valuesFeature(a), valuesFeature(b), valuesFeature(append(append([]float64(nil), a...), b...))
+	for n := 1; n <= len(a); n++ {
+		fa.Update(nil, n)
+	}
+	for n := 1; n <= len(b); n++ {
+		fb.Update(nil, n)
+	}
+	for n := 1; n <= len(a)+len(b); n++ {
+		all.Update(nil, n)
+	}
+	if err := fa.Merge(fb, len(a), len(b)); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(fa.Value()-all.Value()) > 1e-9 || math.Abs(fa.Var()-all.Var()) > 1e-9 ||
+		fa.Min() != 1 || fa.Max() != 12 {
+		t.Fatalf("merged %v, want %v", fa, all)
+	}
+}
+
+func TestModelMergeAtomic(t *testing.T) {
+	load := func(basic string) *signature.Model {
+		b := `{
+  "version": 8, "samples": 4, "areas": ["BasicArea"],
+  "config": {"areas": {"BasicArea": {"features": ["LengthFeature", "HOGHistogramFeature"], "weight": 1}}},
+  "basic": [` + basic + `],
+  "reference": {"width": 10, "height": 10, "cols": 1, "rows": 1, "pixels": [0.5]}
+}`
+		model := new(signature.Model)
+		if err := json.Unmarshal([]byte(b), model); err != nil {
+			t.Fatal(err)
+		}
+		return model
+	}
+	model := load(`{"type": "LengthFeature", "mean": 100, "variance": 4, "std": 2},
+    {"type": "HOGHistogramFeature", "mean": 1, "means": [1, 2], "variances": [1, 1]}`)
+	other := load(`{"type": "LengthFeature", "mean": 50, "variance": 4, "std": 2},
+    {"type": "HOGHistogramFeature", "mean": 1, "means": [1, 2, 3], "variances": [1, 1, 1]}`)
+	other.Reference().Pixels[0] = 1
+	if err := model.Merge(other, 4, 4); err == nil {
+		t.Fatal("merged features of different dimensions")
+	}
+	if v := model.Basic()[features.LengthFeatureType].Value(); v != 100 {
+		t.Errorf("failed merge changed feature to %f", v)
+	}
+	if p := model.Reference().Pixels[0]; p != 0.5 {
+		t.Errorf("failed merge changed reference to %f", p)
+	}
+}
+
+func TestModelMergeConfig(t *testing.T) {
+	sample := inkSample(40, 20, func(r, c int) bool { return r == 10 })
+	extracted := func(config signature.ModelConfig) *signature.Model {
+		model, err := signature.NewModelFromConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := model.Extract(sample, 1); err != nil {
+			t.Fatal(err)
+		}
+		return model
+	}
+	for name, change := range map[string]func(c *signature.ModelConfig){
+		"scorer":    func(c *signature.ModelConfig) { c.Scorer = signature.MahalanobisScorer },
+		"estimator": func(c *signature.ModelConfig) { c.Estimator = features.MedianEstimator },
+		"shrinkage": func(c *signature.ModelConfig) { c.Shrinkage = 0.5 },
+		"deskew":    func(c *signature.ModelConfig) { c.Deskew = true },
+		"features": func(c *signature.ModelConfig) {
+			c.Areas[signature.BasicAreaType] = signature.AreaConfig{
+				Features: []features.FeatureType{features.LengthFeatureType}, Weight: 1,
+			}
+		},
+	} {
+		model := extracted(sampleConfig(t))
+		before, err := json.Marshal(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := sampleConfig(t)
+		change(&config)
+		if err := model.Merge(extracted(config), 1, 1); err == nil {
+			t.Errorf("merged models of different %s", name)
+		}
+		if after, _ := json.Marshal(model); string(after) != string(before) {
+			t.Errorf("failed merge of different %s changed the model", name)
+		}
+	}
+
+	config := sampleConfig(t)
+	config.Debug = true
+	model := extracted(sampleConfig(t))
+	if err := model.Merge(extracted(config), 1, 1); err != nil || model.SamplesCount() != 2 {
+		t.Fatalf("merged models of the same configuration to %d samples: %v", model.SamplesCount(), err)
+	}
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/radekwlsk/handauth/signature"
+	"testing"
+)
+
+func TestModelJSONRoundTrip(t *testing.T) {
+	um := signature.UserModel{Id: 7, Model: signature.NewModel(4, 8, nil)}
+	b, err := json.Marshal(&um)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded signature.UserModel
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != um.Id || loaded.Model.FeaturesCount() != um.Model.FeaturesCount() {
+		t.Fatalf("loaded %d with %d features, want %d with %d",
+			loaded.Id, loaded.Model.FeaturesCount(), um.Id, um.Model.FeaturesCount())
+	}
+	b2, err := json.Marshal(&loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, b2) {
+		t.Fatalf("round trip changed template:\n%s\n%s", b, b2)
+	}
+}
+
+func TestModelBinaryRoundTrip(t *testing.T) {
+	model := signature.NewModel(4, 8, nil)
+	b, err := model.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := new(signature.Model)
+	if err := loaded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	b2, err := loaded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, b2) {
+		t.Fatal("round trip changed template")
+	}
+}
+
+func TestModelUnsupportedVersion(t *testing.T) {
+	if err := json.Unmarshal([]byte(`{"version": 0}`), new(signature.Model)); err == nil {
+		t.Fatal("expected error for unsupported version")
+	}
+}
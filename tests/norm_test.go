@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature"
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestScoreNorm(t *testing.T) {
+	um := signature.UserModel{
+		Id:    3,
+		Model: signature.NewModel(4, 8, nil),
+		Norm: &signature.ScoreNorm{
+			Location: map[signature.AreaType]float64{signature.GridAreaType: 1},
+			Scale: map[signature.AreaType]float64{
+				signature.BasicAreaType: 2,
+				signature.GridAreaType:  0.5,
+			},
+		},
+	}
+	b, err := json.Marshal(&um)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded signature.UserModel
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	score := loaded.Norm.Apply(signature.Score{
+		signature.BasicAreaType: 3,
+		signature.GridAreaType:  2,
+		signature.RowAreaType:   4,
+	})
+	want := signature.Score{
+		signature.BasicAreaType: 1.5,
+		signature.GridAreaType:  2,
+		signature.RowAreaType:   4,
+	}
+	for area, s := range want {
+		if score[area] != s {
+			t.Errorf("normalised %s score %f, want %f", area, score[area], s)
+		}
+	}
+}
+
+func TestNormaliseAppliesZNormAfterNorm(t *testing.T) {
+	um := signature.UserModel{
+		Norm: &signature.ScoreNorm{
+			Scale: map[signature.AreaType]float64{signature.BasicAreaType: 2},
+		},
+		ZNorm: &signature.ScoreNorm{
+			Location: map[signature.AreaType]float64{signature.BasicAreaType: 3},
+			Scale:    map[signature.AreaType]float64{signature.BasicAreaType: 0.5},
+		},
+	}
+	score := um.Normalise(signature.Score{signature.BasicAreaType: 4})
+	if s := score[signature.BasicAreaType]; s != -2 {
+		t.Fatalf("normalised score %f, want -2", s)
+	}
+}
+
+func TestLeaveOneOutNorm(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	config := sampleConfig(t)
+	var ss []*samples.Sample
+	for i := 0; i < 4; i++ {
+		ss = append(ss, strokeSample(rng, 20))
+	}
+	if _, err := signature.LeaveOneOutNorm(config, ss[:2], nil); err == nil {
+		t.Fatal("leave-one-out norm of 2 samples")
+	}
+	norm, err := signature.LeaveOneOutNorm(config, ss, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sums := make(map[signature.AreaType]float64)
+	for i, left := range ss {
+		var others []*samples.Sample
+		for j, s := range ss {
+			if j != i {
+				others = append(others, s)
+			}
+		}
+		score, _ := enroll(t, config, others).Score(left)
+		for area, s := range score {
+			sums[area] += s
+		}
+	}
+	if len(norm.Scale) != len(config.Areas) {
+		t.Fatalf("norm of %d areas, want %d", len(norm.Scale), len(config.Areas))
+	}
+	for area, scale := range norm.Scale {
+		if want := sums[area] / float64(len(ss)); math.Abs(scale-want) > 1e-9 {
+			t.Errorf("%s scale %f, want mean leave-one-out score %f", area, scale, want)
+		}
+	}
+}
+
+// cohortScores returns mean and standard deviation of finite scores of area.
+func cohortScores(scores []signature.Score, area signature.AreaType) (float64, float64) {
+	var values []float64
+	for _, score := range scores {
+		if s := score[area]; !math.IsNaN(s) && !math.IsInf(s, 0) {
+			values = append(values, s)
+		}
+	}
+	return stat.MeanStdDev(values, nil)
+}
+
+func TestZNorm(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	config := sampleConfig(t)
+	var enrolled, cohort []*samples.Sample
+	for i := 0; i < 4; i++ {
+		enrolled = append(enrolled, strokeSample(rng, 20))
+		cohort = append(cohort, strokeSample(rng, 10+10*float64(i)))
+	}
+	um := &signature.UserModel{Id: 1, Model: enroll(t, config, enrolled)}
+	var scores []signature.Score
+	for _, sample := range cohort {
+		score, _ := um.Score(sample)
+		scores = append(scores, score)
+	}
+	if err := um.SetZNorm(cohort); err != nil {
+		t.Fatal(err)
+	}
+	for area := range config.Areas {
+		mean, std := cohortScores(scores, area)
+		if math.Abs(um.ZNorm.Location[area]-mean) > 1e-9 || math.Abs(um.ZNorm.Scale[area]-std) > 1e-9 {
+			t.Errorf("%s z-norm %f/%f, want %f/%f", area, um.ZNorm.Location[area], um.ZNorm.Scale[area], mean, std)
+		}
+	}
+	if err := um.SetZNorm(cohort[:1]); err == nil || um.ZNorm != nil {
+		t.Error("z-norm of a single cohort sample")
+	}
+}
+
+func TestTNorm(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	config := sampleConfig(t)
+	user := func(id uint16, wave float64) *signature.UserModel {
+		var ss []*samples.Sample
+		for i := 0; i < 4; i++ {
+			ss = append(ss, strokeSample(rng, wave))
+		}
+		return &signature.UserModel{Id: id, Model: enroll(t, config, ss)}
+	}
+	um := user(1, 20)
+	// template of the same id is not a cohort template
+	cohort := []*signature.UserModel{user(1, 40), user(2, 10), user(3, 30), user(4, 50)}
+	sample := strokeSample(rng, 20)
+	score, _ := um.Score(sample)
+
+	var scores []signature.Score
+	for _, c := range cohort[1:] {
+		s, _ := c.Score(sample)
+		scores = append(scores, s)
+	}
+	normalised, err := um.TNorm(score, sample, cohort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for area, s := range score {
+		mean, std := cohortScores(scores, area)
+		if want := (s - mean) / std; math.Abs(normalised[area]-want) > 1e-9 {
+			t.Errorf("%s t-normalised score %f, want %f", area, normalised[area], want)
+		}
+	}
+	if _, err := um.TNorm(score, sample, cohort[:2]); err == nil {
+		t.Error("t-norm with a single other cohort template")
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/classifier"
+	"github.com/radekwlsk/handauth/signature/features"
+	"math/rand"
+	"testing"
+)
+
+func TestSVDD(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var x [][]float64
+	for i := 0; i < 50; i++ {
+		x = append(x, []float64{rng.NormFloat64(), rng.NormFloat64(), rng.NormFloat64()})
+	}
+	svdd, err := classifier.TrainSVDD(x, 0.1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := 0
+	for _, xi := range x {
+		if svdd.Score(xi) > 1+1e-6 {
+			outside++
+		}
+	}
+	if outside > 5 {
+		t.Errorf("%d of 50 training samples outside, want at most 5 with nu 0.1", outside)
+	}
+	if s := svdd.Score([]float64{0, 0, 0}); s >= 1 {
+		t.Errorf("center scores %f", s)
+	}
+	if s := svdd.Score([]float64{6, -6, 6}); s <= 1 {
+		t.Errorf("outlier scores %f", s)
+	}
+}
+
+func oneClassModel(t *testing.T, mean float64, length, aspect []float64) *signature.Model {
+	return basicTemplate(t, 4,
+		features.State{Type: features.LengthFeatureType, Mean: mean, Variance: 4, Observations: length},
+		features.State{Type: features.AspectFeatureType, Mean: 2, Variance: 0.01, Observations: aspect},
+	)
+}
+
+func TestOneClass(t *testing.T) {
+	template := oneClassModel(t, 100, []float64{98, 102, 98, 102}, []float64{1.9, 2.1, 2.1, 1.9})
+	oc, err := signature.TrainOneClass(template, signature.DefaultOneClassNu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	um := signature.UserModel{Id: 1, Model: template, OneClass: oc}
+	b, err := json.Marshal(&um)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded signature.UserModel
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.OneClass.Features) != 2 {
+		t.Fatalf("loaded one-class model of %d features, want 2", len(loaded.OneClass.Features))
+	}
+
+	genuine := oneClassModel(t, 100, nil, nil)
+	if s, err := loaded.OneClass.Score(genuine); err != nil || s >= 1 {
+		t.Errorf("template mean scores %f, %v", s, err)
+	}
+	forgery := oneClassModel(t, 120, nil, nil)
+	if s, err := loaded.OneClass.Score(forgery); err != nil || s <= 1 {
+		t.Errorf("distant sample scores %f, %v", s, err)
+	}
+}
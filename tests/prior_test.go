@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/features"
+	"math"
+	"testing"
+)
+
+// basicTemplate returns template enrolled from samples with basic area
+// features of states, standard deviations set from variances.
+func basicTemplate(t *testing.T, samples int, states ...features.State) *signature.Model {
+	var types []features.FeatureType
+	for i := range states {
+		types = append(types, states[i].Type)
+		states[i].Std = math.Sqrt(states[i].Variance)
+	}
+	config, err := json.Marshal(types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic, err := json.Marshal(states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := fmt.Sprintf(`{
+  "version": 8, "samples": %d, "areas": ["BasicArea"],
+  "config": {"areas": {"BasicArea": {"features": %s, "weight": 1}}},
+  "basic": %s
+}`, samples, config, basic)
+	model := new(signature.Model)
+	if err := json.Unmarshal([]byte(b), model); err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func basicModel(t *testing.T, variance float64, observations ...float64) *signature.Model {
+	return basicTemplate(t, 4, features.State{
+		Type: features.LengthFeatureType, Mean: 100, Variance: variance, Observations: observations,
+	})
+}
+
+func TestVariancePrior(t *testing.T) {
+	prior, err := signature.LearnVariancePrior([]*signature.Model{
+		basicModel(t, 4, 98, 102, 98, 102),
+		basicModel(t, 16, 96, 104, 96, 104),
+		basicModel(t, 0, 100, 100, 100, 100),
+	}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := prior.Variances[signature.BasicAreaType][features.LengthFeatureType]; v != 10 {
+		t.Fatalf("prior variance %f, want 10", v)
+	}
+
+	model := basicModel(t, 0, 100, 100, 100, 100)
+	ftr := model.Basic()[features.LengthFeatureType]
+	same, _ := features.Restore(features.State{Type: features.LengthFeatureType, Mean: 100})
+	if s := ftr.Score(same); s != 0 {
+		t.Fatalf("score of equal value against zero variance %f, want 0", s)
+	}
+
+	model.SetVariancePrior(prior)
+	if want := math.Sqrt(4 * 10.0 / 8); math.Abs(ftr.Scale()-want) > 1e-9 {
+		t.Fatalf("MAP scale %f, want %f", ftr.Scale(), want)
+	}
+
+	b, err := json.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := new(signature.Model)
+	if err := json.Unmarshal(b, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Basic()[features.LengthFeatureType].Scale() != ftr.Scale() {
+		t.Fatal("prior not saved with template")
+	}
+}
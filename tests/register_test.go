@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature"
+	"math"
+	"testing"
+)
+
+func lineReference(t *testing.T, ink func(r, c int) bool) *samples.Reference {
+	ref, err := samples.NewReference(200, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < ref.Rows; r++ {
+		for c := 0; c < ref.Cols; c++ {
+			if ink(r, c) {
+				ref.Pixels[r*ref.Cols+c] = 1
+			}
+		}
+	}
+	return ref
+}
+
+func TestReferencePose(t *testing.T) {
+	horizontal := lineReference(t, func(r, c int) bool { return r == 10 })
+	if horizontal.Cols != samples.ReferenceWidth || horizontal.Rows != samples.ReferenceWidth/2 {
+		t.Fatalf("reference kept at %dx%d", horizontal.Cols, horizontal.Rows)
+	}
+	p, ok := horizontal.Pose()
+	if !ok || math.Abs(p.X-100) > 1e-9 || math.Abs(p.Y-21) > 1e-9 || math.Abs(p.Angle) > 1e-9 {
+		t.Errorf("horizontal line pose %+v, %v", p, ok)
+	}
+
+	diagonal := lineReference(t, func(r, c int) bool { return r == c })
+	if p, ok := diagonal.Pose(); !ok || math.Abs(p.Angle-math.Pi/4) > 1e-9 {
+		t.Errorf("diagonal line pose %+v, %v", p, ok)
+	}
+
+	if _, ok := lineReference(t, func(r, c int) bool { return false }).Pose(); ok {
+		t.Error("empty reference has a pose")
+	}
+
+	if err := horizontal.Merge(diagonal, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if v := horizontal.Pixels[10*horizontal.Cols+40]; math.Abs(v-0.75) > 1e-9 {
+		t.Errorf("merged pixel %f, want 0.75", v)
+	}
+	other, _ := samples.NewReference(100, 100)
+	if err := horizontal.Merge(other, 1, 1); err == nil {
+		t.Error("merged references of different sizes")
+	}
+}
+
+func TestSampleRegister(t *testing.T) {
+	ref := lineReference(t, func(r, c int) bool { return r == 10 })
+	rp, _ := ref.Pose()
+
+	// shorter line, moved down and tilted by about 0.1 rad
+	sample := inkSample(200, 100, func(r, c int) bool {
+		return c >= 50 && c < 150 && math.Abs(float64(r)-60-0.1*float64(c-100)) < 1
+	})
+	registered := sample.Register(ref)
+	defer registered.Close()
+	if registered.Width() != 200 || registered.Height() != 100 {
+		t.Fatalf("registered to %dx%d", registered.Width(), registered.Height())
+	}
+	p, ok := registered.Pose()
+	if !ok || math.Abs(p.X-rp.X) > 2 || math.Abs(p.Y-rp.Y) > 2 || math.Abs(p.Angle) > 0.02 {
+		t.Errorf("registered pose %+v, reference pose %+v", p, rp)
+	}
+	// spread of the line is scaled by at most MaxRegisterScale
+	sp, _ := sample.Pose()
+	if want := sp.Spread * samples.MaxRegisterScale; math.Abs(p.Spread-want) > 2 {
+		t.Errorf("registered spread %f, want %f", p.Spread, want)
+	}
+
+	large := inkSample(300, 150, func(r, c int) bool { return r == 75 })
+	registered = large.Register(ref)
+	defer registered.Close()
+	if registered.Width() != 200 || registered.Height() != 100 {
+		t.Errorf("registered %dx%d sample to %dx%d", 300, 150, registered.Width(), registered.Height())
+	}
+
+	empty := inkSample(300, 150, func(r, c int) bool { return false })
+	registered = empty.Register(ref)
+	defer registered.Close()
+	if _, ok := registered.Pose(); ok || registered.Width() != 200 || registered.Height() != 100 {
+		t.Errorf("registered empty sample to %dx%d with ink", registered.Width(), registered.Height())
+	}
+}
+
+func TestExtractReferenceError(t *testing.T) {
+	config := sampleConfig(t)
+	config.Register = true
+	model, err := signature.NewModelFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Extract(inkSample(0, 0, func(r, c int) bool { return false }), 1); err == nil {
+		t.Fatal("extracted sample without size into reference")
+	}
+	if model.Reference() != nil || model.SamplesCount() != 0 {
+		t.Fatal("failed extraction changed the model")
+	}
+}
+
+func TestModelReferenceRoundTrip(t *testing.T) {
+	ref := lineReference(t, func(r, c int) bool { return r == 10 })
+	b, err := json.Marshal(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := []byte(`{"version": 8, "rows": 2, "cols": 2, "areas": [], "reference": ` + string(b) + `}`)
+	model := new(signature.Model)
+	if err := json.Unmarshal(template, model); err != nil {
+		t.Fatal(err)
+	}
+	if model.Reference() == nil || model.Reference().Pixels[10*ref.Cols] != 1 {
+		t.Fatal("reference not loaded")
+	}
+	loaded := new(signature.Model)
+	b, err = json.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, loaded); err != nil || loaded.Reference() == nil {
+		t.Fatalf("reference lost in round trip: %v", err)
+	}
+
+	broken := []byte(`{"version": 8, "rows": 2, "cols": 2, "areas": [],
+		"reference": {"width": 10, "height": 10, "cols": 2, "rows": 2, "pixels": [0]}}`)
+	if err := json.Unmarshal(broken, new(signature.Model)); err == nil {
+		t.Error("loaded reference with missing pixels")
+	}
+}
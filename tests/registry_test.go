@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/features"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func constFeature() *features.Feature {
+	return features.NewFeature(func(*samples.Sample) float64 { return 1.0 })
+}
+
+// registerTestEnv is set in the child process TestRegister runs in.
+const registerTestEnv = "HANDAUTH_REGISTER_TEST"
+
+func TestRegister(t *testing.T) {
+	// registered features stay in default configurations of every model of
+	// the process, so they are registered in a child running only this test
+	if os.Getenv(registerTestEnv) == "" {
+		child := exec.Command(os.Args[0], "-test.run=^TestRegister$")
+		child.Env = append(os.Environ(), registerTestEnv+"=1")
+		if out, err := child.CombinedOutput(); err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		return
+	}
+
+	constFeatureType := features.Register("ConstFeature", constFeature, features.BasicArea|features.RowArea)
+	if constFeatureType.String() != "ConstFeature" {
+		t.Fatalf("registered as %s", constFeatureType)
+	}
+	if parsed, err := features.ParseFeatureType("ConstFeature"); err != nil || parsed != constFeatureType {
+		t.Fatalf("parsed %v, %v", parsed, err)
+	}
+
+	model := signature.NewModel(2, 6, nil)
+	if _, ok := model.Basic()[constFeatureType]; !ok {
+		t.Fatal("registered feature missing in basic area")
+	}
+	if _, ok := model.Row(0)[constFeatureType]; !ok {
+		t.Fatal("registered feature missing in row area")
+	}
+	if _, ok := model.Grid(0, 0)[constFeatureType]; ok {
+		t.Fatal("registered feature present in grid area")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic registering feature twice")
+		}
+	}()
+	features.Register("ConstFeature", constFeature, features.BasicArea)
+}
+
+func TestOptionalFeatureNotInDefaultConfig(t *testing.T) {
+	if !features.HOGHistogramFeatureType.Optional() {
+		t.Fatal("HOGHistogramFeature should be optional")
+	}
+	config := signature.DefaultModelConfig(2, 6)
+	if config.HasFeature(signature.GridAreaType, features.HOGHistogramFeatureType) {
+		t.Fatal("optional feature in default config")
+	}
+	model, err := signature.NewModelFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := model.Grid(1, 5)[features.HOGHistogramFeatureType]; ok {
+		t.Fatal("optional feature extracted in grid area of default config")
+	}
+	count := len(config.Areas[signature.BasicAreaType].Features) +
+		2*6*len(config.Areas[signature.GridAreaType].Features) +
+		2*len(config.Areas[signature.RowAreaType].Features) +
+		6*len(config.Areas[signature.ColAreaType].Features)
+	if model.FeaturesCount() != uint64(count) {
+		t.Fatalf("default model has %d features, want %d configured", model.FeaturesCount(), count)
+	}
+
+	config.Areas[signature.GridAreaType] = signature.AreaConfig{
+		Features: []features.FeatureType{features.HOGHistogramFeatureType},
+		Weight:   1.0,
+	}
+	model, err = signature.NewModelFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := model.Grid(0, 0)[features.HOGHistogramFeatureType]; !ok {
+		t.Fatal("configured optional feature missing in grid area")
+	}
+}
+
+func TestSkewFeature(t *testing.T) {
+	ftrType, err := features.ParseFeatureType("SkewFeature")
+	if err != nil || ftrType != features.SkewFeatureType {
+		t.Fatalf("parsed %s, %v", ftrType, err)
+	}
+	if !ftrType.Optional() || ftrType.Areas() != features.BasicArea {
+		t.Fatal("SkewFeature should be optional and basic only")
+	}
+	if signature.DefaultModelConfig(2, 6).HasFeature(signature.BasicAreaType, ftrType) {
+		t.Fatal("skew in default config")
+	}
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"github.com/radekwlsk/handauth/signature/features"
+	"math"
+	"testing"
+)
+
+func restoreFeature(t *testing.T, mean, std float64, observations []float64) *features.Feature {
+	ftr, err := features.Restore(features.State{
+		Type:         features.LengthFeatureType,
+		Mean:         mean,
+		Variance:     std * std,
+		Std:          std,
+		Observations: observations,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ftr
+}
+
+func TestRobustEstimators(t *testing.T) {
+	// one sloppy enrollment sample out of six
+	observations := []float64{100, 102, 98, 101, 99, 160}
+	template := restoreFeature(t, 110, 22.0, observations)
+	forgery := restoreFeature(t, 130, 0, nil)
+
+	meanScore := template.Score(forgery)
+
+	template.SetEstimator(features.MedianEstimator, 0)
+	if template.Location() != 100.5 {
+		t.Fatalf("median %f, want 100.5", template.Location())
+	}
+	if want := 1.4826 * 1.5; math.Abs(template.Scale()-want) > 1e-9 {
+		t.Fatalf("scaled MAD %f, want %f", template.Scale(), want)
+	}
+	if medianScore := template.Score(forgery); !(medianScore > 5*meanScore) {
+		t.Fatalf("median score %f not much above mean score %f", medianScore, meanScore)
+	}
+
+	template.SetEstimator(features.TrimmedEstimator, 0.2)
+	if template.Location() != 100.5 {
+		t.Fatalf("trimmed mean %f, want 100.5", template.Location())
+	}
+
+	template.SetEstimator(features.MeanEstimator, 0)
+	if template.Location() != 110 || template.Scale() != 22.0 {
+		t.Fatalf("mean estimator gives %f, %f", template.Location(), template.Scale())
+	}
+}
+
+func TestMedianEstimatorEqualObservations(t *testing.T) {
+	// MAD of three equal observations out of five is 0
+	template := restoreFeature(t, 100.4, 2.2, []float64{100, 100, 104, 100, 98})
+	template.SetEstimator(features.MedianEstimator, 0)
+	if want := 1.2533 * 6 / 5; math.Abs(template.Scale()-want) > 1e-9 {
+		t.Fatalf("scale %f, want scaled mean absolute deviation %f", template.Scale(), want)
+	}
+	score := template.Score(restoreFeature(t, 103, 0, nil))
+	if want := 3 / (1.2533 * 6 / 5); !(math.Abs(score-want) <= 1e-9) {
+		t.Fatalf("score %f, want %f", score, want)
+	}
+}
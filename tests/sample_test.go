@@ -3,9 +3,13 @@ package tests
 import (
 	"flag"
 	"github.com/radekwlsk/handauth/cmd"
-	"github.com/radekwlsk/handauth/cmd/flags"
 	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature"
+	"gocv.io/x/gocv"
+	"math"
+	"math/rand"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -15,17 +19,96 @@ const (
 	Index   = 5
 )
 
+// testSampleConfig extracts only features cheap enough to test on drawn
+// samples.
+const testSampleConfig = `{
+  "rows": 2, "cols": 4,
+  "areas": {
+    "BasicArea": {"features": ["LengthFeature", "AspectFeature", "MassCenterXFeature"]},
+    "RowArea": {"features": ["LengthFeature", "MassCenterXFeature"]},
+    "ColArea": {"features": ["LengthFeature", "MassCenterYFeature"]},
+    "GridArea": {"features": ["LengthFeature"]}
+  },
+  "area_filter": {"enabled": true, "field_threshold": 0.03, "rowcol_threshold": 0.02}
+}`
+
+func sampleConfig(t *testing.T) signature.ModelConfig {
+	config, err := signature.LoadModelConfig(strings.NewReader(testSampleConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// inkSample returns binarised sample of given size with ink where ink is true.
+func inkSample(width, height int, ink func(r, c int) bool) *samples.Sample {
+	mat := gocv.NewMatWithSize(height, width, gocv.MatTypeCV8U)
+	for r := 0; r < height; r++ {
+		for c := 0; c < width; c++ {
+			if ink(r, c) {
+				mat.SetUCharAt(r, c, 255)
+			} else {
+				mat.SetUCharAt(r, c, 0)
+			}
+		}
+	}
+	sample := new(samples.Sample)
+	sample.Load(mat)
+	return sample
+}
+
+// strokeSample returns a wave stroke of random amplitude, phase, extent and
+// width, wider with longer waves.
+func strokeSample(rng *rand.Rand, wave float64) *samples.Sample {
+	amp, phase := 15+5*rng.Float64(), 2*math.Pi*rng.Float64()
+	start, end := 10+rng.Intn(30), 160+rng.Intn(30)
+	width := 1 + 2*rng.Float64()
+	return inkSample(200, 80, func(r, c int) bool {
+		y := 40 + amp*math.Sin(float64(c)/wave+phase)
+		return c >= start && c < end && math.Abs(float64(r)-y) < width
+	})
+}
+
+// enroll returns template of config extracted from ss and filtered.
+func enroll(t *testing.T, config signature.ModelConfig, ss []*samples.Sample) *signature.Model {
+	model, err := signature.NewModelFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range ss {
+		if err := model.Extract(s, i+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := model.Filter(); err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func sameScore(a, b signature.Score) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for area, s := range a {
+		o, ok := b[area]
+		if !ok || !(s == o || math.IsNaN(s) && math.IsNaN(o) || math.Abs(s-o) < 1e-9) {
+			return false
+		}
+	}
+	return true
+}
+
 func BenchmarkPreprocessZhang(b *testing.B) {
 	b.SkipNow()
-	resFlag := int(cmd.GPDSResources)
-	flags.Resources = &resFlag
+	config := cmd.Config{Resources: cmd.GPDSResources}
 	testSamples := make([]*samples.Sample, 10)
 	for i := range testSamples {
-		signature, err := cmd.ReadUserSample(uint16(i+1), uint16(i+1), Index)
+		userSample, err := cmd.ReadUserSample(config, uint16(i+1), uint16(i+1), Index)
 		if err != nil {
 			panic(err)
 		}
-		sample := signature.Sample()
+		sample := userSample.Sample()
 		testSamples[i] = sample
 	}
 	b.ResetTimer()
@@ -43,15 +126,14 @@ func BenchmarkPreprocessZhang(b *testing.B) {
 
 func BenchmarkPreprocessNoneThinning(b *testing.B) {
 	b.SkipNow()
-	resFlag := int(cmd.GPDSResources)
-	flags.Resources = &resFlag
+	config := cmd.Config{Resources: cmd.GPDSResources}
 	testSamples := make([]*samples.Sample, 10)
 	for i := range testSamples {
-		signature, err := cmd.ReadUserSample(uint16(i+1), uint16(i+1), Index)
+		userSample, err := cmd.ReadUserSample(config, uint16(i+1), uint16(i+1), Index)
 		if err != nil {
 			panic(err)
 		}
-		sample := signature.Sample()
+		sample := userSample.Sample()
 		testSamples[i] = sample
 	}
 	b.ResetTimer()
@@ -68,15 +150,14 @@ func BenchmarkPreprocessNoneThinning(b *testing.B) {
 
 func BenchmarkZhangThinning(b *testing.B) {
 	b.SkipNow()
-	resFlag := int(cmd.GPDSResources)
-	flags.Resources = &resFlag
+	config := cmd.Config{Resources: cmd.GPDSResources}
 	testSamples := make([]*samples.Sample, 10)
 	for i := range testSamples {
-		signature, err := cmd.ReadUserSample(uint16(i+1), uint16(i+1), Index)
+		userSample, err := cmd.ReadUserSample(config, uint16(i+1), uint16(i+1), Index)
 		if err != nil {
 			panic(err)
 		}
-		sample := signature.Sample()
+		sample := userSample.Sample()
 		sample.Normalize()
 		sample.Foreground()
 		sample.Crop()
@@ -94,11 +175,10 @@ func BenchmarkZhangThinning(b *testing.B) {
 
 func BenchmarkEnroll(b *testing.B) {
 	b.SkipNow()
-	resFlag := int(cmd.GPDSResources)
-	flags.Resources = &resFlag
+	config := cmd.Config{Resources: cmd.GPDSResources, Model: signature.DefaultModelConfig(12, 60)}
 	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
-		cmd.EnrollUser(uint16((n%10)+1), []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 12, 60)
+		_, _ = cmd.EnrollUser(config, uint16((n%10)+1), []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
 	}
 }
 
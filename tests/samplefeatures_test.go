@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature"
+	"math/rand"
+	"testing"
+)
+
+func TestScoreFeatures(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	config := sampleConfig(t)
+	coarse := sampleConfig(t)
+	coarse.Rows, coarse.Cols = 1, 2
+	registered := sampleConfig(t)
+	registered.Register = true
+
+	enrolled := func(config signature.ModelConfig, wave float64) *signature.Model {
+		var ss []*samples.Sample
+		for i := 0; i < 4; i++ {
+			ss = append(ss, strokeSample(rng, wave))
+		}
+		return enroll(t, config, ss)
+	}
+	templates := []*signature.Model{
+		enrolled(config, 10), enrolled(config, 40), enrolled(coarse, 20), enrolled(registered, 20),
+	}
+
+	sample := strokeSample(rng, 20)
+	sf, err := signature.ExtractFeatures(sample, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patterns []*signature.Model
+	for i, template := range templates {
+		score, pattern := template.ScoreFeatures(sf)
+		if want, _ := template.Score(sample); !sameScore(score, want) {
+			t.Errorf("template %d: features score %v, Model.Score gives %v", i, score, want)
+		}
+		patterns = append(patterns, pattern)
+	}
+	if patterns[0] != patterns[1] || patterns[0] == patterns[2] {
+		t.Error("features not shared by templates of the same grid only")
+	}
+
+	um := &signature.UserModel{Id: User, Model: templates[0], Styles: templates[:2]}
+	score, _, style := um.ScoreFeatures(sf)
+	want, _, wantStyle := um.ScoreStyle(sample)
+	if style != wantStyle || !sameScore(score, want) {
+		t.Errorf("features score %v of style %d, ScoreStyle gives %v of style %d", score, style, want, wantStyle)
+	}
+
+	if _, err := signature.ExtractFeatures(sample, signature.ModelConfig{}); err == nil {
+		t.Error("extracted features with invalid configuration")
+	}
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"errors"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/store"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "handauth-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := store.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint16{12, 3} {
+		if err := s.Put(&signature.UserModel{Id: id, Model: signature.NewModel(2, 6, nil)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Put(&signature.UserModel{Id: 5}); err == nil {
+		t.Fatal("expected error storing user without model")
+	}
+
+	ids, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 3 || ids[1] != 12 {
+		t.Fatalf("listed %v, want [3 12]", ids)
+	}
+
+	um, err := s.Get(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if um.Id != 12 || um.Model.FieldsCount() != 12 {
+		t.Fatalf("got user %d with %d fields", um.Id, um.Model.FieldsCount())
+	}
+
+	if err := s.Delete(12); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(12); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(12); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "handauth-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := store.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	um := &signature.UserModel{
+		Id:    7,
+		Model: signature.NewModel(2, 6, nil),
+		Norm:  &signature.ScoreNorm{Scale: map[signature.AreaType]float64{signature.BasicAreaType: 1}},
+	}
+	if err := s.Put(um); err != nil {
+		t.Fatal(err)
+	}
+
+	// every update reads the count written by the previous one
+	const updates = 50
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Update(um.Id, func(um *signature.UserModel) error {
+				um.Norm.Scale[signature.BasicAreaType]++
+				return nil
+			}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	loaded, err := s.Get(um.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := loaded.Norm.Scale[signature.BasicAreaType]; n != 1+updates {
+		t.Fatalf("count %f after %d updates, want %d", n, updates, 1+updates)
+	}
+
+	failed := errors.New("failed")
+	if err := s.Update(um.Id, func(um *signature.UserModel) error {
+		um.Norm.Scale[signature.BasicAreaType] = 0
+		return failed
+	}); err != failed {
+		t.Fatalf("got %v, want error of update", err)
+	}
+	if err := s.Update(um.Id, func(um *signature.UserModel) error {
+		um.Id = 8
+		return nil
+	}); err == nil {
+		t.Fatal("update changed user id")
+	}
+	if loaded, err = s.Get(um.Id); err != nil || loaded.Norm.Scale[signature.BasicAreaType] != 1+updates {
+		t.Fatalf("failed updates saved template: %v", err)
+	}
+	if err := s.Update(9, func(*signature.UserModel) error { return nil }); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("%d files left in store, want 1", len(files))
+	}
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"github.com/radekwlsk/handauth/samples"
+	"github.com/radekwlsk/handauth/signature"
+	"github.com/radekwlsk/handauth/signature/features"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func stylesModel(t *testing.T, length, aspect []float64) *signature.Model {
+	return basicTemplate(t, 8,
+		features.State{Type: features.LengthFeatureType, Mean: 150, Variance: 2500, Observations: length},
+		features.State{Type: features.AspectFeatureType, Mean: 2, Variance: 1, Observations: aspect},
+	)
+}
+
+func TestStyles(t *testing.T) {
+	// full signatures are long and wide, short forms short and narrow
+	model := stylesModel(t,
+		[]float64{200, 99, 201, 100, 199, 101, 200, 100},
+		[]float64{3, 1.1, 3.1, 0.9, 2.9, 1, 3, 1})
+	styles, err := model.Styles(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int{{0, 2, 4, 6}, {1, 3, 5, 7}}
+	if !reflect.DeepEqual(styles, want) && !reflect.DeepEqual(styles, [][]int{want[1], want[0]}) {
+		t.Fatalf("styles %v, want %v", styles, want)
+	}
+
+	model = stylesModel(t,
+		[]float64{150, 140, 160, 155, 145, 150, 135, 165},
+		[]float64{2.2, 1.9, 1.6, 2.4, 2, 1.5, 2.3, 1.8})
+	if styles, err = model.Styles(3); err != nil || len(styles) != 1 {
+		t.Fatalf("single style signer split into %v, %v", styles, err)
+	}
+}
+
+func TestScoreStyleMatchesModelScore(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	config := sampleConfig(t)
+	var short, long []*samples.Sample
+	for i := 0; i < 4; i++ {
+		short = append(short, strokeSample(rng, 10))
+		long = append(long, strokeSample(rng, 40))
+	}
+	um := &signature.UserModel{
+		Id:     1,
+		Model:  enroll(t, config, append(append([]*samples.Sample(nil), short...), long...)),
+		Styles: []*signature.Model{enroll(t, config, short), enroll(t, config, long)},
+	}
+	for _, wave := range []float64{10, 40} {
+		sample := strokeSample(rng, wave)
+		score, _, style := um.ScoreStyle(sample)
+		if style < 0 {
+			t.Fatal("no style matched")
+		}
+		want, _ := um.Styles[style].Score(sample)
+		if !sameScore(score, want) {
+			t.Errorf("style %d scores %v, Model.Score gives %v", style, score, want)
+		}
+	}
+}
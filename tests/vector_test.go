@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/radekwlsk/handauth/signature/features"
+	"math"
+	"testing"
+)
+
+func TestVectorMetrics(t *testing.T) {
+	p, q := []float64{0.5, 0.5, 0}, []float64{0.25, 0.25, 0.5}
+	for _, c := range []struct {
+		metric features.Metric
+		want   float64
+	}{
+		{features.StdScoreMetric, 2.5},
+		{features.ChiSquareMetric, (0.25*0.25/0.75*2 + 0.5) / 2},
+		{features.BhattacharyyaMetric, -math.Log(2 * math.Sqrt(0.125))},
+	} {
+		template, err := features.Restore(features.State{
+			Type: features.HOGHistogramFeatureType, Metric: c.metric,
+			Means: p, Variances: []float64{0.01, 0, 0.04},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range []struct {
+			means []float64
+			want  float64
+		}{{q, c.want}, {p, 0}} {
+			sample, err := features.Restore(features.State{
+				Type: features.HOGHistogramFeatureType, Means: s.means, Variances: make([]float64, len(s.means)),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := template.Score(sample); math.Abs(d-s.want) > 1e-9 {
+				t.Errorf("%v of %v and %v: %f, want %f", c.metric, p, s.means, d, s.want)
+			}
+		}
+
+		b, err := json.Marshal(template.State())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var state features.State
+		if err := json.Unmarshal(b, &state); err != nil || state.Metric != c.metric {
+			t.Errorf("metric %v loaded as %v: %v", c.metric, state.Metric, err)
+		}
+	}
+}